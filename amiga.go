@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"path"
+	"strings"
+)
+
+// amigaRGB4 converts a color to the 12 bit $0RGB format of the OCS color registers.
+func amigaRGB4(c color.Color) uint16 {
+	r, g, b, _ := c.RGBA()
+	return uint16(r>>12)<<8 | uint16(g>>12)<<4 | uint16(b>>12)
+}
+
+// amigaBob encodes img as interleaved bitplanes, i.e. for every row the row of each plane after
+// another, with rows padded to full 16 bit words. The mask uses the same layout with the mask row
+// repeated for every plane, as the blitter expects for cookie-cut drawing. The number of planes
+// is the smallest that fits the colors of the frame, transparency included.
+func amigaBob(img image.Image) (planes, mask, palette []byte, err error) {
+	f, indexErr := newIndexedFrame(img, 32, amigaRGB4)
+	if indexErr != nil {
+		return nil, nil, nil, indexErr
+	}
+	depth := 1
+	for 1<<uint(depth) < len(f.palette) {
+		depth++
+	}
+
+	b := img.Bounds()
+	words := (b.Dx() + 15) / 16
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		rowPlanes := make([][]uint16, depth)
+		rowMask := make([]uint16, words)
+		for plane := range rowPlanes {
+			rowPlanes[plane] = make([]uint16, words)
+		}
+		for x := 0; x < b.Dx(); x++ {
+			index := f.index(b.Min.X+x, y)
+			bit := uint16(0x8000) >> uint(x%16)
+			if _, _, _, a := img.At(b.Min.X+x, y).RGBA(); a != 0 {
+				rowMask[x/16] |= bit
+			}
+			for plane := range rowPlanes {
+				if index&(1<<uint(plane)) != 0 {
+					rowPlanes[plane][x/16] |= bit
+				}
+			}
+		}
+		for plane := range rowPlanes {
+			planes = appendWords(planes, rowPlanes[plane])
+			mask = appendWords(mask, rowMask)
+		}
+	}
+	return planes, mask, f.paletteData(1<<uint(depth), binary.BigEndian), nil
+}
+
+// amigaSprites encodes img as hardware sprites of 16 pixels width and 3 colors. Wider frames
+// are split into several sprites from left to right. Each sprite consists of two empty control
+// words, a data word for each of both planes per row, and two terminating zero words.
+func amigaSprites(img image.Image) (data, palette []byte, err error) {
+	f, indexErr := newIndexedFrame(img, 4, amigaRGB4)
+	if indexErr != nil {
+		return nil, nil, indexErr
+	}
+	b := img.Bounds()
+	for sx := b.Min.X; sx < b.Max.X; sx += 16 {
+		data = appendWords(data, []uint16{0, 0})
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			var low, high uint16
+			for x := 0; x < 16; x++ {
+				index := f.index(sx+x, y)
+				bit := uint16(0x8000) >> uint(x)
+				if index&1 != 0 {
+					low |= bit
+				}
+				if index&2 != 0 {
+					high |= bit
+				}
+			}
+			data = appendWords(data, []uint16{low, high})
+		}
+		data = appendWords(data, []uint16{0, 0})
+	}
+	return data, f.paletteData(4, binary.BigEndian), nil
+}
+
+func appendWords(data []byte, words []uint16) []byte {
+	for _, w := range words {
+		data = binary.BigEndian.AppendUint16(data, w)
+	}
+	return data
+}
+
+// saveAmiga writes img as Amiga bob (.bpl and .msk) or hardware sprite (.aspr) data next to the
+// frame file, and its palette into a .pal file.
+func saveAmiga(img image.Image, mode string, frameFilename string) error {
+	base := strings.TrimSuffix(frameFilename, path.Ext(frameFilename))
+	outputs := make(map[string][]byte)
+	var palette []byte
+	var encodeErr error
+	if mode == "sprite" {
+		outputs[".aspr"], palette, encodeErr = amigaSprites(img)
+	} else {
+		outputs[".bpl"], outputs[".msk"], palette, encodeErr = amigaBob(img)
+	}
+	if encodeErr != nil {
+		return fmt.Errorf("cannot encode Amiga %s for %s: %v", mode, frameFilename, encodeErr)
+	}
+	for ext, data := range outputs {
+		if writeErr := writeFile(base+ext, data, 0644); writeErr != nil {
+			return writeErr
+		}
+	}
+	return savePalette(palette, frameFilename)
+}
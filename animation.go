@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rowAnimation is the sequence of written frames of one row of the sprite map and mirror variant.
+type rowAnimation struct {
+	Row       int
+	Animation string
+	Mirror    string
+	Frames    []frameFile
+}
+
+// Name returns the animation name, or the row index if the animation has no name, followed by
+// the mirror variant if there is one.
+func (r *rowAnimation) Name() string {
+	name := r.Animation
+	if name == "" {
+		name = strconv.Itoa(r.Row)
+	}
+	if r.Mirror != "" {
+		return name + "-" + r.Mirror
+	}
+	return name
+}
+
+// Size returns the size of the largest frame of the animation.
+func (r *rowAnimation) Size() image.Point {
+	var size image.Point
+	for _, f := range r.Frames {
+		size.X = max(size.X, f.Size.X)
+		size.Y = max(size.Y, f.Size.Y)
+	}
+	return size
+}
+
+// Duration returns the total duration of the animation in milliseconds.
+func (r *rowAnimation) Duration() int {
+	duration := 0
+	for _, f := range r.Frames {
+		duration += f.Duration
+	}
+	return duration
+}
+
+// rowAnimations groups the written frames by row and mirror variant, keeping their order.
+func rowAnimations(files []frameFile) []*rowAnimation {
+	var animations []*rowAnimation
+	byKey := make(map[string]*rowAnimation)
+	for _, f := range files {
+		key := strconv.Itoa(f.Row) + "-" + f.Mirror
+		a, ok := byKey[key]
+		if !ok {
+			a = &rowAnimation{Row: f.Row, Animation: f.Animation, Mirror: f.Mirror}
+			byKey[key] = a
+			animations = append(animations, a)
+		}
+		a.Frames = append(a.Frames, f)
+	}
+	return animations
+}
+
+// gifPalette returns a palette for the frames, with transparency at index 0. If the frames use
+// too many colors, the web safe palette is used instead.
+func gifPalette(frames []frameFile) color.Palette {
+	p := color.Palette{color.Transparent}
+	seen := make(map[color.NRGBA]bool)
+	for _, f := range frames {
+		b := f.Image.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := color.NRGBAModel.Convert(f.Image.At(x, y)).(color.NRGBA)
+				if c.A < 128 {
+					continue
+				}
+				// all remaining pixels become opaque, so colors differing only in alpha share an entry
+				c.A = 255
+				if seen[c] {
+					continue
+				}
+				seen[c] = true
+				if len(p) < 256 {
+					p = append(p, c)
+				}
+			}
+		}
+	}
+	if len(seen) >= 256 {
+		return append(color.Palette{color.Transparent}, palette.WebSafe...)
+	}
+	return p
+}
+
+// encodeGIFAnimation returns the animation as an endlessly looping GIF, showing every frame for
+// its duration. Pixels that are less than half opaque become transparent.
+func encodeGIFAnimation(a *rowAnimation) *gif.GIF {
+	p := gifPalette(a.Frames)
+	size := a.Size()
+	anim := &gif.GIF{Config: image.Config{ColorModel: p, Width: size.X, Height: size.Y}}
+	for _, f := range a.Frames {
+		frame := image.NewPaletted(image.Rectangle{Max: size}, p)
+		b := f.Image.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := color.NRGBAModel.Convert(f.Image.At(x, y)).(color.NRGBA)
+				if c.A < 128 {
+					continue
+				}
+				c.A = 255
+				frame.SetColorIndex(x-b.Min.X, y-b.Min.Y, uint8(p[1:].Index(c)+1))
+			}
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, max(f.Duration/10, 1))
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+	return anim
+}
+
+// writeRowPreviews writes an animated GIF for every row of written frames into dir, named
+// <base>-<animation name or row index>.gif.
+func writeRowPreviews(dir string, base string, files []frameFile) error {
+	if mkdirErr := os.MkdirAll(dir, 0755); mkdirErr != nil {
+		return mkdirErr
+	}
+	for _, a := range rowAnimations(files) {
+		file, createErr := createFile(filepath.Join(dir, base+"-"+a.Name()+".gif"))
+		if createErr != nil {
+			return createErr
+		}
+		encodeErr := gif.EncodeAll(file, encodeGIFAnimation(a))
+		closeErr := file.Close()
+		if encodeErr != nil {
+			return encodeErr
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	return nil
+}
+
+// onionSkinOpacity is the opacity every frame is drawn with in an onion skin composite.
+const onionSkinOpacity = 96
+
+// onionSkin composites all frames of the animation on top of each other at reduced opacity.
+func onionSkin(a *rowAnimation) image.Image {
+	composite := image.NewNRGBA(image.Rectangle{Max: a.Size()})
+	mask := image.NewUniform(color.Alpha{A: onionSkinOpacity})
+	for _, f := range a.Frames {
+		draw.DrawMask(composite, composite.Bounds(), f.Image, f.Image.Bounds().Min, mask, image.Point{}, draw.Over)
+	}
+	return composite
+}
+
+// writeOnionSkins writes an onion skin composite of every row of written frames into dir, named
+// <base>-<animation name or row index>-onion.png.
+func writeOnionSkins(dir string, base string, files []frameFile) error {
+	if mkdirErr := os.MkdirAll(dir, 0755); mkdirErr != nil {
+		return mkdirErr
+	}
+	for _, a := range rowAnimations(files) {
+		filename := filepath.Join(dir, base+"-"+a.Name()+"-onion.png")
+		if !saveImage(onionSkin(a), filename) {
+			return fmt.Errorf("cannot write %s", filename)
+		}
+	}
+	return nil
+}
+
+// fpsFlag holds the frames per second of animations, given on the command line either for all
+// rows or for a single row as row=fps.
+type fpsFlag struct {
+	Default uint
+	Rows    map[int]uint
+}
+
+func (f *fpsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	values := []string{strconv.FormatUint(uint64(f.Default), 10)}
+	for row, fps := range f.Rows {
+		values = append(values, strconv.Itoa(row)+"="+strconv.FormatUint(uint64(fps), 10))
+	}
+	sort.Strings(values[1:])
+	return strings.Join(values, " ")
+}
+
+func (f *fpsFlag) Set(value string) error {
+	rowText, fpsText, perRow := strings.Cut(value, "=")
+	if !perRow {
+		fpsText = rowText
+	}
+	fps, parseErr := strconv.ParseUint(fpsText, 10, 32)
+	if parseErr != nil {
+		return parseErr
+	}
+	if fps == 0 {
+		return fmt.Errorf("fps must not be 0")
+	}
+	if !perRow {
+		f.Default = uint(fps)
+		return nil
+	}
+	row, rowErr := strconv.Atoi(rowText)
+	if rowErr != nil {
+		return rowErr
+	}
+	if f.Rows == nil {
+		f.Rows = make(map[int]uint)
+	}
+	f.Rows[row] = uint(fps)
+	return nil
+}
+
+// Duration returns the display duration of the cell in milliseconds. The fps given for its row
+// take precedence over the cell's own duration, which takes precedence over the default fps.
+func (f *fpsFlag) Duration(c cell) int {
+	if fps, ok := f.Rows[c.Row]; ok {
+		return max(1000/int(fps), 1)
+	}
+	if c.Duration != 0 {
+		return c.Duration
+	}
+	return max(1000/int(f.Default), 1)
+}
+
+// animationGroup is an animation defined on the command line as name=rows:first-last or
+// name=cells:row:column-row:column, spanning the cells of the grid in reading order.
+type animationGroup struct {
+	Name  string
+	First image.Point
+	Last  image.Point
+}
+
+// contains reports whether the cell at row, column lies within the group in reading order.
+func (g *animationGroup) contains(row int, column int) bool {
+	afterFirst := row > g.First.Y || row == g.First.Y && column >= g.First.X
+	beforeLast := row < g.Last.Y || row == g.Last.Y && column <= g.Last.X
+	return afterFirst && beforeLast
+}
+
+// animationList collects the animation groups given by repeated -anim flags.
+type animationList []animationGroup
+
+func (l *animationList) String() string {
+	var specs []string
+	for _, g := range *l {
+		specs = append(specs, fmt.Sprintf("%s=cells:%d:%d-%d:%d", g.Name, g.First.Y, g.First.X, g.Last.Y, g.Last.X))
+	}
+	return strings.Join(specs, " ")
+}
+
+func (l *animationList) Set(value string) error {
+	name, spec, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=rows:first-last or name=cells:row:column-row:column")
+	}
+	kind, span, _ := strings.Cut(spec, ":")
+	firstText, lastText, isRange := strings.Cut(span, "-")
+	if !isRange {
+		lastText = firstText
+	}
+	g := animationGroup{Name: name}
+	switch kind {
+	case "rows":
+		first, firstErr := strconv.Atoi(firstText)
+		if firstErr != nil {
+			return firstErr
+		}
+		last, lastErr := strconv.Atoi(lastText)
+		if lastErr != nil {
+			return lastErr
+		}
+		g.First, g.Last = image.Pt(0, first), image.Pt(math.MaxInt, last)
+	case "cells":
+		var first, last cellIndex
+		if firstErr := first.Set(strings.Replace(firstText, ":", ",", 1)); firstErr != nil {
+			return firstErr
+		}
+		if lastErr := last.Set(strings.Replace(lastText, ":", ",", 1)); lastErr != nil {
+			return lastErr
+		}
+		g.First, g.Last = image.Pt(first.Column, first.Row), image.Pt(last.Column, last.Row)
+	default:
+		return fmt.Errorf("unknown animation span %q, supported are rows and cells", kind)
+	}
+	if !g.contains(g.First.Y, g.First.X) {
+		return fmt.Errorf("animation %s ends before it starts", name)
+	}
+	*l = append(*l, g)
+	return nil
+}
+
+// cells returns the cells of every animation group in reading order, with the animations as rows
+// and the frames of an animation as columns. The cells are named <animation>-<index>.
+func (l animationList) cells(grid []cell) []cell {
+	var cells []cell
+	for row, g := range l {
+		column := 0
+		for _, c := range grid {
+			if !g.contains(c.Row, c.Column) {
+				continue
+			}
+			cells = append(cells, cell{Rect: c.Rect, Row: row, Column: column, Name: g.Name + "-" + strconv.Itoa(column), Animation: g.Name})
+			column++
+		}
+	}
+	return cells
+}
+
+// rowNameList is the comma separated list of -row-names, naming the rows from the top. Rows with an
+// empty name keep their index.
+type rowNameList []string
+
+func (l *rowNameList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *rowNameList) Set(value string) error {
+	*l = strings.Split(value, ",")
+	return nil
+}
+
+// rowNameCells names the cells of the rows given by -row-names <row name>-<column index>, with
+// the row name as their animation. Cells that already have a name are left as they are.
+func (a *args) rowNameCells(cells []cell) []cell {
+	columns := 0
+	for _, c := range cells {
+		columns = max(columns, c.Column+1)
+	}
+	digits := a.indexDigits(columns)
+	named := make([]cell, len(cells))
+	for i, c := range cells {
+		if c.Name == "" && c.Row < len(a.RowNames) && a.RowNames[c.Row] != "" {
+			c.Animation = a.RowNames[c.Row]
+			c.Name = fmt.Sprintf("%s-%0*d", c.Animation, digits, c.Column+int(a.StartIndex))
+		}
+		named[i] = c
+	}
+	return named
+}
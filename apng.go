@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writePNGChunk writes one PNG chunk with its length and checksum.
+func writePNGChunk(w io.Writer, kind string, data []byte) error {
+	header := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	header = append(header, kind...)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	if _, writeErr := w.Write(header); writeErr != nil {
+		return writeErr
+	}
+	if _, writeErr := w.Write(data); writeErr != nil {
+		return writeErr
+	}
+	_, writeErr := w.Write(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+	return writeErr
+}
+
+// apngImageData returns the compressed 8 bit RGBA image data of a frame placed at the top left of a
+// canvas of the given size.
+func apngImageData(img image.Image, size image.Point) []byte {
+	var data bytes.Buffer
+	z := zlib.NewWriter(&data)
+	b := img.Bounds()
+	row := make([]byte, 1+4*size.X)
+	for y := 0; y < size.Y; y++ {
+		clear(row)
+		for x := 0; x < size.X && x < b.Dx() && y < b.Dy(); x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			copy(row[1+4*x:], []byte{c.R, c.G, c.B, c.A})
+		}
+		z.Write(row)
+	}
+	z.Close()
+	return data.Bytes()
+}
+
+// encodeAPNGAnimation writes the animation as an endlessly looping animated PNG, showing every
+// frame for its duration.
+func encodeAPNGAnimation(w io.Writer, a *rowAnimation) error {
+	size := a.Size()
+	if _, writeErr := io.WriteString(w, "\x89PNG\r\n\x1a\n"); writeErr != nil {
+		return writeErr
+	}
+	ihdr := binary.BigEndian.AppendUint32(nil, uint32(size.X))
+	ihdr = binary.BigEndian.AppendUint32(ihdr, uint32(size.Y))
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+	if chunkErr := writePNGChunk(w, "IHDR", ihdr); chunkErr != nil {
+		return chunkErr
+	}
+	actl := binary.BigEndian.AppendUint32(nil, uint32(len(a.Frames)))
+	actl = binary.BigEndian.AppendUint32(actl, 0)
+	if chunkErr := writePNGChunk(w, "acTL", actl); chunkErr != nil {
+		return chunkErr
+	}
+
+	var sequence uint32
+	for i, f := range a.Frames {
+		fctl := binary.BigEndian.AppendUint32(nil, sequence)
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(size.X))
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(size.Y))
+		fctl = binary.BigEndian.AppendUint32(fctl, 0)
+		fctl = binary.BigEndian.AppendUint32(fctl, 0)
+		fctl = binary.BigEndian.AppendUint16(fctl, uint16(min(f.Duration, 0xffff)))
+		fctl = binary.BigEndian.AppendUint16(fctl, 1000)
+		fctl = append(fctl, 0, 0)
+		if chunkErr := writePNGChunk(w, "fcTL", fctl); chunkErr != nil {
+			return chunkErr
+		}
+		sequence++
+
+		data := apngImageData(f.Image, size)
+		if i == 0 {
+			if chunkErr := writePNGChunk(w, "IDAT", data); chunkErr != nil {
+				return chunkErr
+			}
+			continue
+		}
+		fdat := binary.BigEndian.AppendUint32(nil, sequence)
+		if chunkErr := writePNGChunk(w, "fdAT", append(fdat, data...)); chunkErr != nil {
+			return chunkErr
+		}
+		sequence++
+	}
+	return writePNGChunk(w, "IEND", nil)
+}
+
+// writeRowAPNGs writes an animated PNG for every row of written frames into dir, named
+// <base>-<animation name or row index>.png.
+func writeRowAPNGs(dir string, base string, files []frameFile) error {
+	if mkdirErr := os.MkdirAll(dir, 0755); mkdirErr != nil {
+		return mkdirErr
+	}
+	for _, a := range rowAnimations(files) {
+		file, createErr := createFile(filepath.Join(dir, base+"-"+a.Name()+".png"))
+		if createErr != nil {
+			return createErr
+		}
+		encodeErr := encodeAPNGAnimation(file, a)
+		closeErr := file.Close()
+		if encodeErr != nil {
+			return encodeErr
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Chunk types of .ase files that are needed to flatten the frames.
+const (
+	aseChunkOldPalette = 0x0004
+	aseChunkLayer      = 0x2004
+	aseChunkCel        = 0x2005
+	aseChunkTags       = 0x2018
+	aseChunkPalette    = 0x2019
+)
+
+// asepriteTagDirections maps the loop directions of .ase tags to those of the JSON data file.
+var asepriteTagDirections = []string{"forward", "reverse", "pingpong", "pingpong_reverse"}
+
+// aseLayer is a layer of an .ase file.
+type aseLayer struct {
+	Name    string
+	Visible bool
+	Opacity uint8
+	Level   int
+}
+
+// aseCel is the image of a layer in one frame.
+type aseCel struct {
+	Layer   int
+	Origin  image.Point
+	Opacity uint8
+	Image   *image.NRGBA
+}
+
+// errAseTruncated is returned for chunks that are too small for their content.
+var errAseTruncated = errors.New("chunk is truncated")
+
+// aseMaxSheetPixels limits the size of the sprite map of all frames side by side to 1 GiB, so
+// that the dimensions in a header cannot request arbitrary amounts of memory.
+const aseMaxSheetPixels = 1 << 28
+
+// aseReader reads the little endian values of an .ase file.
+type aseReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *aseReader) read(v any) {
+	if r.err == nil {
+		r.err = binary.Read(r.r, binary.LittleEndian, v)
+	}
+}
+
+func (r *aseReader) word() uint16 {
+	var v uint16
+	r.read(&v)
+	return v
+}
+
+func (r *aseReader) skip(n int) {
+	if n < 0 && r.err == nil {
+		r.err = errAseTruncated
+	}
+	if r.err == nil {
+		_, r.err = io.CopyN(io.Discard, r.r, int64(n))
+	}
+}
+
+func (r *aseReader) string() string {
+	text := make([]byte, r.word())
+	r.read(text)
+	return string(text)
+}
+
+// asePixels converts pixels of the color depth of the file into an image of the given size.
+// Indexed pixels of the transparent index are transparent.
+func asePixels(data []byte, width int, height int, depth uint16, palette color.Palette, transparent uint8) (*image.NRGBA, error) {
+	size := int(depth) / 8
+	if len(data) < width*height*size {
+		return nil, fmt.Errorf("cel pixels are truncated")
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		p := data[i*size:]
+		var c color.NRGBA
+		switch depth {
+		case 32:
+			c = color.NRGBA{R: p[0], G: p[1], B: p[2], A: p[3]}
+		case 16:
+			c = color.NRGBA{R: p[0], G: p[0], B: p[0], A: p[1]}
+		default:
+			if p[0] != transparent && int(p[0]) < len(palette) {
+				c = palette[p[0]].(color.NRGBA)
+			}
+		}
+		img.SetNRGBA(i%width, i/width, c)
+	}
+	return img, nil
+}
+
+// readAseCel reads a cel chunk of the given size. Linked cels are returned with the frame they
+// link to and no image. The pixels are read as far as the chunk and the cel size reach, so that
+// neither a forged chunk size nor a compressed cel can allocate more than the pixels of the cel.
+func readAseCel(r *aseReader, size int, depth uint16, palette color.Palette, transparent uint8) (*aseCel, int, error) {
+	cel := &aseCel{Layer: int(r.word())}
+	var x, y int16
+	r.read(&x)
+	r.read(&y)
+	r.read(&cel.Opacity)
+	cel.Origin = image.Pt(int(x), int(y))
+	celType := r.word()
+	r.skip(7)
+	size -= 16
+	switch celType {
+	case 0, 2:
+		width, height := int(r.word()), int(r.word())
+		if size < 4 {
+			return nil, 0, errAseTruncated
+		}
+		if width*height > aseMaxSheetPixels {
+			return nil, 0, fmt.Errorf("cel of %dx%d is too large", width, height)
+		}
+		if r.err != nil {
+			return nil, 0, r.err
+		}
+		data, readErr := io.ReadAll(io.LimitReader(r.r, int64(size-4)))
+		if readErr != nil {
+			return nil, 0, readErr
+		}
+		if len(data) < size-4 {
+			return nil, 0, errAseTruncated
+		}
+		if celType == 2 {
+			z, zlibErr := zlib.NewReader(bytes.NewReader(data))
+			if zlibErr != nil {
+				return nil, 0, zlibErr
+			}
+			if data, readErr = io.ReadAll(io.LimitReader(z, int64(width*height*int(depth)/8))); readErr != nil {
+				return nil, 0, readErr
+			}
+		}
+		var pixelsErr error
+		cel.Image, pixelsErr = asePixels(data, width, height, depth, palette, transparent)
+		return cel, -1, pixelsErr
+	case 1:
+		linked := int(r.word())
+		r.skip(size - 2)
+		return cel, linked, r.err
+	default:
+		// tilemaps are not supported
+		r.skip(size)
+		return nil, -1, r.err
+	}
+}
+
+// loadAseFile reads an .ase or .aseprite file and lays out its flattened frames side by side in a
+// single row sprite map. Only visible layers, or the layer of the given name, are drawn, all with
+// normal blending. The cells are named and grouped by the tags like those exported by Aseprite.
+func loadAseFile(filename string, layer string) (SpriteMap, []cell, error) {
+	data, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		return nil, nil, readErr
+	}
+	r := &aseReader{r: bytes.NewReader(data)}
+	var header struct {
+		FileSize    uint32
+		Magic       uint16
+		Frames      uint16
+		Width       uint16
+		Height      uint16
+		Depth       uint16
+		Flags       uint32
+		Speed       uint16
+		_           [8]byte
+		Transparent uint8
+	}
+	r.read(&header)
+	r.skip(128 - 29)
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	if header.Magic != 0xa5e0 {
+		return nil, nil, fmt.Errorf("not an Aseprite file")
+	}
+	if header.Depth != 32 && header.Depth != 16 && header.Depth != 8 {
+		return nil, nil, fmt.Errorf("unsupported color depth %d", header.Depth)
+	}
+	width, height := int(header.Width), int(header.Height)
+	switch {
+	case int(header.FileSize) != len(data):
+		return nil, nil, fmt.Errorf("file size %d does not match the header's %d", len(data), header.FileSize)
+	case header.Frames == 0 || width == 0 || height == 0:
+		return nil, nil, fmt.Errorf("empty sprite of %d frames of %dx%d", header.Frames, width, height)
+	case 128+16*int(header.Frames) > len(data):
+		return nil, nil, fmt.Errorf("%d frames do not fit into %d bytes", header.Frames, len(data))
+	case width*int(header.Frames)*height > aseMaxSheetPixels:
+		return nil, nil, fmt.Errorf("%d frames of %dx%d are too large", header.Frames, width, height)
+	}
+
+	var layers []aseLayer
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.NRGBA{}
+	}
+	var export asepriteExport
+	cels := make([]map[int]*aseCel, header.Frames)
+	for frame := range int(header.Frames) {
+		var frameHeader struct {
+			Size      uint32
+			Magic     uint16
+			OldChunks uint16
+			Duration  uint16
+			_         [2]byte
+			Chunks    uint32
+		}
+		r.read(&frameHeader)
+		if r.err != nil {
+			return nil, nil, r.err
+		}
+		if frameHeader.Magic != 0xf1fa {
+			return nil, nil, fmt.Errorf("frame %d is corrupt", frame)
+		}
+		chunks := int(frameHeader.Chunks)
+		if chunks == 0 {
+			chunks = int(frameHeader.OldChunks)
+		}
+
+		cels[frame] = make(map[int]*aseCel)
+		for range chunks {
+			var chunkSize uint32
+			r.read(&chunkSize)
+			chunkType := r.word()
+			size := int(chunkSize) - 6
+			switch chunkType {
+			case aseChunkLayer:
+				flags := r.word()
+				r.skip(2)
+				l := aseLayer{Visible: flags&1 != 0, Level: int(r.word())}
+				r.skip(6)
+				r.read(&l.Opacity)
+				r.skip(3)
+				l.Name = r.string()
+				r.skip(size - 18 - len(l.Name))
+				layers = append(layers, l)
+			case aseChunkCel:
+				cel, linked, celErr := readAseCel(r, size, header.Depth, palette, header.Transparent)
+				if celErr != nil {
+					return nil, nil, celErr
+				}
+				if linked >= 0 && linked < frame {
+					if source, ok := cels[linked][cel.Layer]; ok {
+						cel.Image, cel.Origin = source.Image, source.Origin
+					}
+				}
+				if cel != nil && cel.Image != nil {
+					cels[frame][cel.Layer] = cel
+				}
+			case aseChunkPalette:
+				var first, last uint32
+				r.skip(4)
+				r.read(&first)
+				r.read(&last)
+				r.skip(8)
+				for index := first; index <= last && r.err == nil; index++ {
+					flags := r.word()
+					var c color.NRGBA
+					r.read(&c)
+					if flags&1 != 0 {
+						r.string()
+					}
+					if index < 256 {
+						palette[index] = c
+					}
+				}
+			case aseChunkOldPalette:
+				index := 0
+				for range r.word() {
+					var skip, count uint8
+					r.read(&skip)
+					r.read(&count)
+					index += int(skip)
+					n := int(count)
+					if n == 0 {
+						n = 256
+					}
+					for range n {
+						var rgb [3]uint8
+						r.read(&rgb)
+						if index < 256 {
+							palette[index] = color.NRGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}
+						}
+						index++
+					}
+				}
+			case aseChunkTags:
+				count := r.word()
+				r.skip(8)
+				for range count {
+					from, to := r.word(), r.word()
+					var direction uint8
+					r.read(&direction)
+					r.skip(12)
+					name := r.string()
+					if int(direction) >= len(asepriteTagDirections) {
+						direction = 0
+					}
+					export.Meta.FrameTags = append(export.Meta.FrameTags, asepriteTag{Name: name, From: int(from), To: int(to), Direction: asepriteTagDirections[direction]})
+				}
+			default:
+				r.skip(size)
+			}
+			if r.err != nil {
+				return nil, nil, fmt.Errorf("frame %d: %v", frame, r.err)
+			}
+		}
+
+		var f asepriteFrame
+		f.Filename = strconv.Itoa(frame)
+		f.Frame.X, f.Frame.W, f.Frame.H = frame*width, width, height
+		f.Duration = int(frameHeader.Duration)
+		export.Frames = append(export.Frames, f)
+	}
+
+	sheet := image.NewNRGBA(image.Rect(0, 0, width*int(header.Frames), height))
+	visible := aseVisibleLayers(layers, layer, header.Flags&1 != 0)
+	for frame := range cels {
+		origin := image.Pt(frame*width, 0)
+		canvas := sheet.SubImage(image.Rectangle{Min: origin, Max: origin.Add(image.Pt(width, height))}).(*image.NRGBA)
+		for index := range layers {
+			cel, ok := cels[frame][index]
+			opacity, show := visible[index]
+			if !ok || !show {
+				continue
+			}
+			alpha := uint8(int(cel.Opacity) * int(opacity) / 255)
+			target := cel.Image.Bounds().Add(origin).Add(cel.Origin)
+			draw.DrawMask(canvas, target, cel.Image, image.Point{}, image.NewUniform(color.Alpha{A: alpha}), image.Point{}, draw.Over)
+		}
+	}
+
+	cells, cellsErr := export.cells()
+	return sheet, cells, cellsErr
+}
+
+// aseVisibleLayers returns the opacity of every layer that is drawn, which are the visible
+// layers within visible groups, or only the layer of the given name if it is not empty.
+func aseVisibleLayers(layers []aseLayer, name string, opacityValid bool) map[int]uint8 {
+	visible := make(map[int]uint8)
+	hiddenBelow := -1
+	for i, l := range layers {
+		if hiddenBelow >= 0 && l.Level > hiddenBelow {
+			continue
+		}
+		hiddenBelow = -1
+		if !l.Visible && name == "" {
+			hiddenBelow = l.Level
+			continue
+		}
+		if name != "" && l.Name != name {
+			continue
+		}
+		opacity := uint8(255)
+		if opacityValid {
+			opacity = l.Opacity
+		}
+		visible[i] = opacity
+	}
+	return visible
+}
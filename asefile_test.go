@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// aseFixture builds an .ase file with 8 bit indexed pixels chunk by chunk.
+type aseFixture struct {
+	frames [][]byte
+}
+
+// le returns the values in little endian byte order.
+func le(values ...any) []byte {
+	var b bytes.Buffer
+	for _, v := range values {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	return b.Bytes()
+}
+
+func aseString(s string) []byte {
+	return append(le(uint16(len(s))), s...)
+}
+
+// chunk appends a chunk of the given type to the last frame. size overrides the chunk size if
+// it is not 0.
+func (f *aseFixture) chunk(chunkType uint16, size uint32, data ...[]byte) {
+	body := bytes.Join(data, nil)
+	if size == 0 {
+		size = uint32(6 + len(body))
+	}
+	last := len(f.frames) - 1
+	f.frames[last] = append(f.frames[last], le(size, chunkType)...)
+	f.frames[last] = append(f.frames[last], body...)
+}
+
+func (f *aseFixture) frame() {
+	f.frames = append(f.frames, nil)
+}
+
+func (f *aseFixture) layer(name string, visible bool) {
+	flags := uint16(0)
+	if visible {
+		flags = 1
+	}
+	f.chunk(aseChunkLayer, 0, le(flags, uint16(0), uint16(0), [6]byte{}, uint8(255), [3]byte{}), aseString(name))
+}
+
+func (f *aseFixture) cel(layer uint16, x int16, y int16, width uint16, height uint16, pixels []byte) {
+	f.chunk(aseChunkCel, 0, le(layer, x, y, uint8(255), uint16(0), [7]byte{}, width, height), pixels)
+}
+
+func (f *aseFixture) compressedCel(layer uint16, x int16, y int16, width uint16, height uint16, pixels []byte) {
+	var compressed bytes.Buffer
+	z := zlib.NewWriter(&compressed)
+	z.Write(pixels)
+	z.Close()
+	f.chunk(aseChunkCel, 0, le(layer, x, y, uint8(255), uint16(2), [7]byte{}, width, height), compressed.Bytes())
+}
+
+func (f *aseFixture) linkedCel(layer uint16, frame uint16) {
+	f.chunk(aseChunkCel, 0, le(layer, int16(0), int16(0), uint8(255), uint16(1), [7]byte{}, frame))
+}
+
+func (f *aseFixture) palette(colors ...color.NRGBA) {
+	entries := [][]byte{le(uint32(len(colors)), uint32(1), uint32(len(colors)), [8]byte{})}
+	for _, c := range colors {
+		entries = append(entries, le(uint16(0), c))
+	}
+	f.chunk(aseChunkPalette, 0, entries...)
+}
+
+func (f *aseFixture) tag(name string, from uint16, to uint16) {
+	f.chunk(aseChunkTags, 0, le(uint16(1), [8]byte{}, from, to, uint8(0), [12]byte{}), aseString(name))
+}
+
+// bytes returns the file with frames of width x height pixels.
+func (f *aseFixture) bytes(width uint16, height uint16) []byte {
+	var body []byte
+	for _, chunks := range f.frames {
+		count := 0
+		for r := bytes.NewReader(chunks); r.Len() > 0; count++ {
+			var size uint32
+			binary.Read(r, binary.LittleEndian, &size)
+			r.Seek(int64(size)-4, 1)
+		}
+		body = append(body, le(uint32(16+len(chunks)), uint16(0xf1fa), uint16(count), uint16(100), [2]byte{}, uint32(count))...)
+		body = append(body, chunks...)
+	}
+	header := le(uint32(128+len(body)), uint16(0xa5e0), uint16(len(f.frames)), width, height, uint16(8), uint32(1), uint16(100), [8]byte{}, uint8(0))
+	header = append(header, make([]byte, 128-len(header))...)
+	return append(header, body...)
+}
+
+// loadAseBytes writes the file to a temporary directory and loads it.
+func loadAseBytes(t *testing.T, data []byte, layer string) (SpriteMap, []cell, error) {
+	filename := filepath.Join(t.TempDir(), "sprite.aseprite")
+	if writeErr := os.WriteFile(filename, data, 0644); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	return loadAseFile(filename, layer)
+}
+
+// aseWalk returns a sprite of two 4x2 frames tagged walk. The visible background is red, the
+// hidden layer green, and the top layer has a blue pixel, at 1,0 in the first and at 2,1 in the
+// second frame, which links the background cel of the first frame.
+func aseWalk() *aseFixture {
+	red, green, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{G: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+	var f aseFixture
+	f.frame()
+	f.palette(red, green, blue)
+	f.layer("background", true)
+	f.layer("hidden", false)
+	f.layer("top", true)
+	f.tag("walk", 0, 1)
+	f.cel(0, 0, 0, 4, 2, bytes.Repeat([]byte{1}, 8))
+	f.cel(1, 0, 0, 4, 2, bytes.Repeat([]byte{2}, 8))
+	f.compressedCel(2, 1, 0, 1, 1, []byte{3})
+	f.frame()
+	f.linkedCel(0, 0)
+	f.compressedCel(2, 2, 1, 1, 1, []byte{3})
+	return &f
+}
+
+func TestLoadAseFile(t *testing.T) {
+	red, green, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{G: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+	tests := []struct {
+		name  string
+		layer string
+		// pixels are those of the sprite map with both frames side by side
+		pixels [][]color.NRGBA
+	}{
+		{
+			name: "visible layers",
+			pixels: [][]color.NRGBA{
+				{red, blue, red, red, red, red, red, red},
+				{red, red, red, red, red, red, blue, red},
+			},
+		},
+		{
+			name:  "hidden layer by name",
+			layer: "hidden",
+			pixels: [][]color.NRGBA{
+				{green, green, green, green, {}, {}, {}, {}},
+				{green, green, green, green, {}, {}, {}, {}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sheet, cells, loadErr := loadAseBytes(t, aseWalk().bytes(4, 2), test.layer)
+			if loadErr != nil {
+				t.Fatal(loadErr)
+			}
+			if size := sheet.Bounds().Size(); size != image.Pt(8, 2) {
+				t.Fatalf("size is %v, expected 8x2", size)
+			}
+			for y, row := range test.pixels {
+				for x, expected := range row {
+					if c := color.NRGBAModel.Convert(sheet.At(x, y)); c != expected {
+						t.Errorf("pixel %d,%d is %v, expected %v", x, y, c, expected)
+					}
+				}
+			}
+			expected := []cell{
+				{Rect: image.Rect(0, 0, 4, 2), Name: "walk-0", Animation: "walk", Duration: 100},
+				{Rect: image.Rect(4, 0, 8, 2), Column: 1, Name: "walk-1", Animation: "walk", Duration: 100},
+			}
+			if len(cells) != len(expected) {
+				t.Fatalf("got the cells %v, expected %v", cells, expected)
+			}
+			for i := range cells {
+				if cells[i] != expected[i] {
+					t.Errorf("cell %d is %v, expected %v", i, cells[i], expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadAseFileErrors(t *testing.T) {
+	truncatedChunk := aseWalk()
+	truncatedChunk.chunk(aseChunkCel, 1000, le(uint16(0), int16(0), int16(0), uint8(255), uint16(0), [7]byte{}, uint16(4), uint16(2)))
+
+	var hugeCel aseFixture
+	hugeCel.frame()
+	hugeCel.compressedCel(0, 0, 0, 65535, 65535, nil)
+
+	withoutFrames := aseWalk().bytes(4, 2)
+	binary.LittleEndian.PutUint16(withoutFrames[6:], 0)
+	tooManyFrames := aseWalk().bytes(4, 2)
+	binary.LittleEndian.PutUint16(tooManyFrames[6:], 1000)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated file", data: aseWalk().bytes(4, 2)[:200]},
+		{name: "truncated chunk", data: truncatedChunk.bytes(4, 2)},
+		{name: "cel too large", data: hugeCel.bytes(4, 2)},
+		{name: "sprite too large", data: aseWalk().bytes(65535, 65535)},
+		{name: "no frames", data: withoutFrames},
+		{name: "more frames than the file holds", data: tooManyFrames},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, loadErr := loadAseBytes(t, test.data, ""); loadErr == nil {
+				t.Error("loading does not fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// asepriteFrame is a frame of the JSON data file written by Aseprite.
+type asepriteFrame struct {
+	Filename string
+	Frame    struct {
+		X, Y, W, H int
+	}
+	Duration int
+}
+
+// asepriteFrames are the frames of a JSON data file in the array or the hash format, in the order
+// they appear in the file.
+type asepriteFrames []asepriteFrame
+
+func (f *asepriteFrames) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.Unmarshal(data, (*[]asepriteFrame)(f))
+	}
+	var hash map[string]asepriteFrame
+	if hashErr := json.Unmarshal(data, &hash); hashErr != nil {
+		return hashErr
+	}
+	names, keysErr := jsonObjectKeys(data)
+	if keysErr != nil {
+		return keysErr
+	}
+	for _, name := range names {
+		frame := hash[name]
+		frame.Filename = name
+		*f = append(*f, frame)
+	}
+	return nil
+}
+
+// asepriteTag is a tag of the JSON data file written by Aseprite, naming the frames from From to To.
+type asepriteTag struct {
+	Name      string
+	From      int
+	To        int
+	Direction string
+}
+
+// asepriteExport is the JSON data file written by Aseprite.
+type asepriteExport struct {
+	Frames asepriteFrames
+	Meta   struct {
+		FrameTags []asepriteTag
+		Slices    []struct {
+			Name string
+			Keys []struct {
+				Frame  int
+				Bounds struct {
+					X, Y, W, H int
+				}
+			}
+		}
+	}
+}
+
+func isAsepriteFile(filename string) bool {
+	ext := strings.ToLower(path.Ext(filename))
+	return ext == ".ase" || ext == ".aseprite"
+}
+
+// loadAseprite exports filename with the Aseprite CLI into a temporary sprite map and data file and
+// returns the sprite map together with the cells described by the data file.
+func loadAseprite(bin, filename, layer string) (SpriteMap, []cell, error) {
+	dir, tempErr := os.MkdirTemp("", "spritemap-explode")
+	if tempErr != nil {
+		return nil, nil, tempErr
+	}
+	defer os.RemoveAll(dir)
+
+	sheetFilename := filepath.Join(dir, "sheet.png")
+	dataFilename := filepath.Join(dir, "sheet.json")
+	cmdArgs := []string{"-b"}
+	if layer != "" {
+		cmdArgs = append(cmdArgs, "--layer", layer)
+	}
+	cmdArgs = append(cmdArgs, filename, "--sheet", sheetFilename, "--data", dataFilename,
+		"--format", "json-array", "--list-tags", "--filename-format", "{frame}")
+	cmd := exec.Command(bin, cmdArgs...)
+	cmd.Stderr = os.Stderr
+	if runErr := cmd.Run(); runErr != nil {
+		return nil, nil, runErr
+	}
+
+	data, readErr := os.ReadFile(dataFilename)
+	if readErr != nil {
+		return nil, nil, readErr
+	}
+	var export asepriteExport
+	if unmarshalErr := json.Unmarshal(data, &export); unmarshalErr != nil {
+		return nil, nil, fmt.Errorf("cannot parse data file: %v", unmarshalErr)
+	}
+
+	file, openErr := os.Open(sheetFilename)
+	if openErr != nil {
+		return nil, nil, openErr
+	}
+	defer file.Close()
+	img, _, decodeErr := image.Decode(file)
+	if decodeErr != nil {
+		return nil, nil, decodeErr
+	}
+	spriteMap, ok := img.(SpriteMap)
+	if !ok {
+		return nil, nil, fmt.Errorf("exported sprite map does not support extracting sub-images")
+	}
+
+	cells, cellsErr := export.cells()
+	return spriteMap, cells, cellsErr
+}
+
+// cells returns one row of cells per tag, in the order the tag's direction plays them. Without
+// tags, all frames are returned in a single row.
+func (e *asepriteExport) cells() ([]cell, error) {
+	rects := make(map[int]image.Rectangle, len(e.Frames))
+	durations := make(map[int]int, len(e.Frames))
+	for _, f := range e.Frames {
+		index, atoiErr := strconv.Atoi(f.Filename)
+		if atoiErr != nil {
+			return nil, fmt.Errorf("unexpected frame name %q", f.Filename)
+		}
+		rects[index] = image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H)
+		durations[index] = f.Duration
+	}
+
+	var cells []cell
+	if len(e.Meta.FrameTags) == 0 {
+		for index := 0; index < len(e.Frames); index++ {
+			cells = append(cells, cell{Rect: rects[index], Column: index, Name: strconv.Itoa(index), Duration: durations[index]})
+		}
+		return cells, nil
+	}
+
+	for row, tag := range e.Meta.FrameTags {
+		var order []int
+		for index := tag.From; index <= tag.To; index++ {
+			order = append(order, index)
+		}
+		switch tag.Direction {
+		case "reverse":
+			reverseInts(order)
+		case "pingpong":
+			for i := len(order) - 2; i > 0; i-- {
+				order = append(order, order[i])
+			}
+		case "pingpong_reverse":
+			reverseInts(order)
+			for i := len(order) - 2; i > 0; i-- {
+				order = append(order, order[i])
+			}
+		}
+		for column, index := range order {
+			rect, ok := rects[index]
+			if !ok {
+				return nil, fmt.Errorf("tag %s refers to unknown frame %d", tag.Name, index)
+			}
+			cells = append(cells, cell{Rect: rect, Row: row, Column: column, Name: tag.Name + "-" + strconv.Itoa(column), Animation: tag.Name, Duration: durations[index]})
+		}
+	}
+	return cells, nil
+}
+
+func reverseInts(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// loadAsepriteJSON reads a JSON data file exported by Aseprite along with the sprite map, in the
+// array or the hash format, and returns the cells of its tags like for Aseprite files. Every slice
+// adds a cell named after it at its bounds in the first frame it has a key for, in a row after the
+// tags.
+func loadAsepriteJSON(filename string) ([]cell, error) {
+	data, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		return nil, readErr
+	}
+	var export asepriteExport
+	if unmarshalErr := json.Unmarshal(data, &export); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	for i := range export.Frames {
+		export.Frames[i].Filename = strconv.Itoa(i)
+	}
+	cells, cellsErr := export.cells()
+	if cellsErr != nil {
+		return nil, cellsErr
+	}
+
+	row := 0
+	for _, c := range cells {
+		row = max(row, c.Row+1)
+	}
+	column := 0
+	for _, slice := range export.Meta.Slices {
+		if len(slice.Keys) == 0 {
+			continue
+		}
+		key := slice.Keys[0]
+		if key.Frame < 0 || key.Frame >= len(export.Frames) {
+			return nil, fmt.Errorf("slice %s refers to unknown frame %d", slice.Name, key.Frame)
+		}
+		frame := export.Frames[key.Frame].Frame
+		origin := image.Pt(frame.X+key.Bounds.X, frame.Y+key.Bounds.Y)
+		rect := image.Rectangle{Min: origin, Max: origin.Add(image.Pt(key.Bounds.W, key.Bounds.H))}
+		cells = append(cells, cell{Rect: rect, Row: row, Column: column, Name: slice.Name})
+		column++
+	}
+	return cells, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAsepriteJSONSlices(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "sheet.json")
+	data := `{"frames": {
+		"sheet 0.ase": {"frame": {"x": 0, "y": 0, "w": 16, "h": 16}, "duration": 100},
+		"sheet 1.ase": {"frame": {"x": 16, "y": 0, "w": 16, "h": 16}, "duration": 100}
+	}, "meta": {"slices": [
+		{"name": "hitbox", "keys": [{"frame": 1, "bounds": {"x": 2, "y": 3, "w": 4, "h": 5}}]},
+		{"name": "unused", "keys": []},
+		{"name": "weapon", "keys": [{"frame": 0, "bounds": {"x": 8, "y": 8, "w": 2, "h": 2}}]}
+	]}}`
+	if writeErr := os.WriteFile(filename, []byte(data), 0644); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	cells, loadErr := loadAsepriteJSON(filename)
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+	expected := []cell{
+		{Rect: image.Rect(0, 0, 16, 16), Name: "0", Duration: 100},
+		{Rect: image.Rect(16, 0, 32, 16), Column: 1, Name: "1", Duration: 100},
+		{Rect: image.Rect(18, 3, 22, 8), Row: 1, Name: "hitbox"},
+		{Rect: image.Rect(8, 8, 10, 10), Row: 1, Column: 1, Name: "weapon"},
+	}
+	if len(cells) != len(expected) {
+		t.Fatalf("got the cells %v, expected %v", cells, expected)
+	}
+	for i := range cells {
+		if cells[i] != expected[i] {
+			t.Errorf("cell %d is %v, expected %v", i, cells[i], expected[i])
+		}
+	}
+}
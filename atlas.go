@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path"
+	"strings"
+)
+
+// atlasRect is a rectangle in a TexturePacker JSON atlas.
+type atlasRect struct {
+	X, Y, W, H int
+}
+
+// atlasFrame is a frame of a TexturePacker JSON atlas. Frame is the area in the atlas image, with
+// width and height swapped if the frame is rotated by 90 degrees, clockwise unless Counterclockwise
+// is set like for Spine atlases. Trimmed frames are placed at SpriteSourceSize within a frame of
+// SourceSize.
+type atlasFrame struct {
+	Filename         string
+	Frame            atlasRect
+	Rotated          bool
+	Counterclockwise bool `json:"-"`
+	Trimmed          bool
+	SpriteSourceSize atlasRect
+	SourceSize       struct{ W, H int }
+}
+
+// jsonObjectKeys returns the keys of the JSON object in data in the order they appear, as the order
+// of frames in hashes is meaningful even though JSON objects are unordered.
+func jsonObjectKeys(data []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, tokenErr := decoder.Token(); tokenErr != nil {
+		return nil, tokenErr
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	for decoder.More() {
+		key, tokenErr := decoder.Token()
+		if tokenErr != nil {
+			return nil, tokenErr
+		}
+		var value json.RawMessage
+		if decodeErr := decoder.Decode(&value); decodeErr != nil {
+			return nil, decodeErr
+		}
+		if name, _ := key.(string); !seen[name] {
+			seen[name] = true
+			keys = append(keys, name)
+		}
+	}
+	return keys, nil
+}
+
+// loadAtlasFrames reads the frames of a TexturePacker JSON atlas in the hash or the array format.
+// Frames of the hash format keep the order of the file, like those of Aseprite data files.
+func loadAtlasFrames(filename string) ([]atlasFrame, error) {
+	data, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		return nil, readErr
+	}
+	var atlas struct {
+		Frames json.RawMessage
+	}
+	if unmarshalErr := json.Unmarshal(data, &atlas); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	var frames []atlasFrame
+	if arrayErr := json.Unmarshal(atlas.Frames, &frames); arrayErr == nil {
+		return frames, nil
+	}
+	var hash map[string]atlasFrame
+	if hashErr := json.Unmarshal(atlas.Frames, &hash); hashErr != nil {
+		return nil, fmt.Errorf("frames are neither an array nor a hash: %v", hashErr)
+	}
+	names, keysErr := jsonObjectKeys(atlas.Frames)
+	if keysErr != nil {
+		return nil, keysErr
+	}
+	for _, name := range names {
+		f := hash[name]
+		f.Filename = name
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// image returns the frame cut from the atlas image, rotated back and with the trimmed transparent
+// border restored.
+func (f *atlasFrame) image(atlas image.Image) image.Image {
+	size := image.Pt(f.Frame.W, f.Frame.H)
+	if f.Rotated {
+		size = image.Pt(f.Frame.H, f.Frame.W)
+	}
+	origin := atlas.Bounds().Min.Add(image.Pt(f.Frame.X, f.Frame.Y))
+	frame := image.NewNRGBA(image.Rectangle{Max: size})
+	draw.Draw(frame, frame.Bounds(), atlas, origin, draw.Src)
+	var img image.Image = frame
+	if f.Rotated && f.Counterclockwise {
+		img = imageRotate90(img)
+	} else if f.Rotated {
+		img = imageRotate270(img)
+	}
+	if !f.Trimmed {
+		return img
+	}
+	untrimmed := image.NewNRGBA(image.Rect(0, 0, f.SourceSize.W, f.SourceSize.H))
+	offset := image.Pt(f.SpriteSourceSize.X, f.SpriteSourceSize.Y)
+	draw.Draw(untrimmed, img.Bounds().Sub(img.Bounds().Min).Add(offset), img, img.Bounds().Min, draw.Src)
+	return untrimmed
+}
+
+// loadAtlas lays out the frames of the TexturePacker JSON atlas, or the Spine atlas if the file
+// ends with .atlas, side by side in a single row sprite map, one cell per frame named after the
+// frame without its extension. imageFilename is the page of a Spine atlas to read.
+func loadAtlas(atlasFilename string, imageFilename string, img image.Image) (SpriteMap, []cell, error) {
+	var frames []atlasFrame
+	var loadErr error
+	if strings.EqualFold(path.Ext(atlasFilename), ".atlas") {
+		frames, loadErr = loadSpineAtlas(atlasFilename, imageFilename)
+	} else {
+		frames, loadErr = loadAtlasFrames(atlasFilename)
+	}
+	if loadErr != nil {
+		return nil, nil, loadErr
+	}
+	if len(frames) == 0 {
+		return nil, nil, fmt.Errorf("no frames in %s", atlasFilename)
+	}
+
+	images := make([]image.Image, len(frames))
+	width, height := 0, 0
+	for i := range frames {
+		images[i] = frames[i].image(img)
+		width += images[i].Bounds().Dx()
+		height = max(height, images[i].Bounds().Dy())
+	}
+	sheet := image.NewNRGBA(image.Rect(0, 0, width, height))
+	cells := make([]cell, len(frames))
+	x := 0
+	for i, f := range frames {
+		b := images[i].Bounds()
+		rect := image.Rect(x, 0, x+b.Dx(), b.Dy())
+		draw.Draw(sheet, rect, images[i], b.Min, draw.Src)
+		name := strings.TrimSuffix(f.Filename, path.Ext(f.Filename))
+		cells[i] = cell{Rect: rect, Column: i, Name: strings.ReplaceAll(name, "/", "-")}
+		x += b.Dx()
+	}
+	return sheet, cells, nil
+}
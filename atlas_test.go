@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAtlasFramesKeepsHashOrder(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "atlas.json")
+	atlas := `{"frames": {
+		"walk-2.png": {"frame": {"x": 0, "y": 0, "w": 1, "h": 1}},
+		"walk-10.png": {"frame": {"x": 1, "y": 0, "w": 1, "h": 1}},
+		"idle.png": {"frame": {"x": 2, "y": 0, "w": 1, "h": 1}}
+	}}`
+	if writeErr := os.WriteFile(filename, []byte(atlas), 0644); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	frames, loadErr := loadAtlasFrames(filename)
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+	expected := []string{"walk-2.png", "walk-10.png", "idle.png"}
+	if len(frames) != len(expected) {
+		t.Fatalf("got %d frames, expected %d", len(frames), len(expected))
+	}
+	for i, f := range frames {
+		if f.Filename != expected[i] || f.Frame.X != i {
+			t.Errorf("frame %d is %s at %d, expected %s at %d", i, f.Filename, f.Frame.X, expected[i], i)
+		}
+	}
+}
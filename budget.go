@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// checkSizeBudget checks the sizes of the written frame files against the -max-frame-bytes and
+// -max-total-bytes budgets, which are ignored if 0. If a budget is exceeded, a report of all frame
+// sizes, largest first, is written to w and an error is returned.
+func checkSizeBudget(w io.Writer, files []frameFile, maxFrame int64, maxTotal int64) error {
+	type frameSize struct {
+		Filename string
+		Bytes    int64
+	}
+	sizes := make([]frameSize, 0, len(files))
+	var total int64
+	oversized := 0
+	for _, f := range files {
+		info, statErr := os.Stat(f.Filename)
+		if statErr != nil {
+			return statErr
+		}
+		sizes = append(sizes, frameSize{Filename: f.Filename, Bytes: info.Size()})
+		total += info.Size()
+		if maxFrame != 0 && info.Size() > maxFrame {
+			oversized++
+		}
+	}
+	totalExceeded := maxTotal != 0 && total > maxTotal
+	if oversized == 0 && !totalExceeded {
+		return nil
+	}
+
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].Bytes > sizes[j].Bytes })
+	for _, s := range sizes {
+		marker := ""
+		if maxFrame != 0 && s.Bytes > maxFrame {
+			marker = " (over budget)"
+		}
+		fmt.Fprintf(w, "%10d %s%s\n", s.Bytes, s.Filename, marker)
+	}
+	fmt.Fprintf(w, "%10d total\n", total)
+
+	if totalExceeded {
+		return fmt.Errorf("frames take %d bytes, the budget is %d bytes", total, maxTotal)
+	}
+	return fmt.Errorf("%d frames exceed the budget of %d bytes", oversized, maxFrame)
+}
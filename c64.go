@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	c64SpriteWidth  = 24
+	c64SpriteHeight = 21
+)
+
+// c64Sprites splits img into C64 hardware sprites of 24x21 pixels and encodes each into a
+// 64 byte record, the 63 bytes of sprite data followed by a padding byte. It also returns the
+// position of every sprite relative to the frame.
+//
+// In hires mode, every non-transparent pixel is set. In multicolor mode, every second pixel
+// is ignored because the pixels are twice as wide, and the up to 3 colors of the frame are
+// assigned to the bit pairs 01, 10 and 11 from dark to light.
+func c64Sprites(img image.Image, multicolor bool) ([]byte, []image.Point, error) {
+	b := img.Bounds()
+	var colorBits map[color.RGBA64]byte
+	if multicolor {
+		var palette []color.Color
+		colorBits = make(map[color.RGBA64]byte)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x += 2 {
+				c := color.RGBA64Model.Convert(img.At(x, y)).(color.RGBA64)
+				if _, seen := colorBits[c]; !seen && c.A != 0 {
+					colorBits[c] = 0
+					palette = append(palette, c)
+				}
+			}
+		}
+		if len(palette) > 3 {
+			return nil, nil, fmt.Errorf("frame uses %d colors, but multicolor sprites only support 3", len(palette))
+		}
+		sort.SliceStable(palette, func(i, j int) bool { return luminance(palette[i]) < luminance(palette[j]) })
+		for i, c := range palette {
+			colorBits[c.(color.RGBA64)] = byte(i + 1)
+		}
+	}
+
+	var data []byte
+	var offsets []image.Point
+	for sy := b.Min.Y; sy < b.Max.Y; sy += c64SpriteHeight {
+		for sx := b.Min.X; sx < b.Max.X; sx += c64SpriteWidth {
+			var record [64]byte
+			for y := 0; y < c64SpriteHeight; y++ {
+				for x := 0; x < c64SpriteWidth; x++ {
+					c := color.RGBA64Model.Convert(pixelAt(img, sx+x, sy+y)).(color.RGBA64)
+					if c.A == 0 {
+						continue
+					}
+					if !multicolor {
+						record[3*y+x/8] |= 0x80 >> uint(x%8)
+					} else if x%2 == 0 {
+						record[3*y+x/8] |= colorBits[c] << uint(6-x%8)
+					}
+				}
+			}
+			data = append(data, record[:]...)
+			offsets = append(offsets, image.Pt(sx-b.Min.X, sy-b.Min.Y))
+		}
+	}
+	return data, offsets, nil
+}
+
+// saveC64Sprites writes img as C64 sprite data into a .spr file next to the frame file, and the
+// offsets of its hardware sprites as assembler source into a .inc file.
+func saveC64Sprites(img image.Image, multicolor bool, frameFilename string) error {
+	base := strings.TrimSuffix(frameFilename, path.Ext(frameFilename))
+	data, offsets, encodeErr := c64Sprites(img, multicolor)
+	if encodeErr != nil {
+		return fmt.Errorf("cannot encode C64 sprites for %s: %v", frameFilename, encodeErr)
+	}
+	if writeErr := writeFile(base+".spr", data, 0644); writeErr != nil {
+		return writeErr
+	}
+
+	var inc bytes.Buffer
+	fmt.Fprintf(&inc, "; %s: x and y offsets in pixels of the hardware sprites (%d)\n", path.Base(base), len(offsets))
+	for _, offset := range offsets {
+		fmt.Fprintf(&inc, ".byte %d, %d\n", offset.X, offset.Y)
+	}
+	return writeFile(base+".inc", inc.Bytes(), 0644)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// charmapName returns the name of the frame showing r: the character itself if it is an ASCII
+// lower case letter or digit, prefixed with upper_ if it is an upper case letter so that the files
+// of a and A do not collide on case insensitive file systems, otherwise its code point in hex, e.g.
+// 0x263A.
+func charmapName(r rune) string {
+	if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+		return string(r)
+	}
+	if r >= 'A' && r <= 'Z' {
+		return "upper_" + string(r)
+	}
+	return fmt.Sprintf("0x%04X", r)
+}
+
+// loadCharmap returns the characters of the -charmap or -charmap-file argument. Line breaks in a
+// file are ignored, so it may be laid out like the sheet.
+func (a *args) loadCharmap() ([]rune, error) {
+	if a.CharmapFile == "" {
+		return []rune(a.Charmap), nil
+	}
+	data, readErr := os.ReadFile(a.CharmapFile)
+	if readErr != nil {
+		return nil, readErr
+	}
+	return []rune(strings.NewReplacer("\r", "", "\n", "").Replace(string(data))), nil
+}
+
+// charmapCells names the cells in reading order by the characters of chars. Empty cells use up a
+// character as well, so that e.g. a space lines up with a blank cell. Cells beyond the end of
+// chars are left out.
+func charmapCells(grid []cell, chars []rune) []cell {
+	cells := make([]cell, 0, min(len(grid), len(chars)))
+	for i, c := range grid {
+		if i >= len(chars) {
+			break
+		}
+		c.Name = charmapName(chars[i])
+		cells = append(cells, c)
+	}
+	return cells
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// writtenFiles are the files written by the current run of process, which -checksums covers.
+var writtenFiles []string
+
+// writeFile is os.WriteFile for outputs, which adds filename to writtenFiles.
+func writeFile(filename string, data []byte, perm os.FileMode) error {
+	if writeErr := os.WriteFile(filename, data, perm); writeErr != nil {
+		return writeErr
+	}
+	writtenFiles = append(writtenFiles, filename)
+	return nil
+}
+
+// createFile is os.Create for outputs, which adds filename to writtenFiles.
+func createFile(filename string) (*os.File, error) {
+	file, createErr := os.Create(filename)
+	if createErr != nil {
+		return nil, createErr
+	}
+	writtenFiles = append(writtenFiles, filename)
+	return file, nil
+}
+
+// writeChecksums writes the SHA-256 sums of all files written by the run to filename in the
+// format of sha256sum, with paths relative to the directory of filename so they can be verified
+// there with sha256sum -c. Files that have been removed again, like images that could not be
+// encoded, are left out.
+func writeChecksums(filename string, outputs []string) error {
+	paths := make(map[string]string)
+	for _, output := range outputs {
+		rel, relErr := filepath.Rel(filepath.Dir(filename), output)
+		if relErr != nil {
+			return relErr
+		}
+		paths[filepath.ToSlash(rel)] = output
+	}
+	rels := make([]string, 0, len(paths))
+	for rel := range paths {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	var sums strings.Builder
+	for _, rel := range rels {
+		file, openErr := os.Open(paths[rel])
+		if os.IsNotExist(openErr) {
+			continue
+		}
+		if openErr != nil {
+			return openErr
+		}
+		hash := sha256.New()
+		_, copyErr := io.Copy(hash, file)
+		file.Close()
+		if copyErr != nil {
+			return copyErr
+		}
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(hash.Sum(nil)), rel)
+	}
+	return os.WriteFile(filename, []byte(sums.String()), 0644)
+}
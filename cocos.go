@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+// xmlText returns s escaped for XML character data.
+func xmlText(s string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(s))
+	return escaped.String()
+}
+
+// writeCocos writes a Cocos2d sprite frame property list in format 3 to <base>.plist. The regions
+// are not rotated. The offset of a trimmed region is that of the center of its visible part from
+// the center of the untrimmed frame, with y counting upwards.
+func writeCocos(e *sheetExport) error {
+	filename := e.Base + ".plist"
+	image := xmlText(e.relImage(filename))
+
+	var plist bytes.Buffer
+	plist.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>frames</key>
+    <dict>
+`)
+	for _, r := range e.Regions {
+		frame, visible := r.frame(), r.frame().Sub(r.Rect.Min)
+		offsetX := float64(visible.Min.X+visible.Max.X-r.Rect.Dx()) / 2
+		offsetY := float64(r.Rect.Dy()-visible.Min.Y-visible.Max.Y) / 2
+		fmt.Fprintf(&plist, `        <key>%s</key>
+        <dict>
+            <key>aliases</key>
+            <array/>
+            <key>spriteOffset</key>
+            <string>{%g,%g}</string>
+            <key>spriteSize</key>
+            <string>{%d,%d}</string>
+            <key>spriteSourceSize</key>
+            <string>{%d,%d}</string>
+            <key>textureRect</key>
+            <string>{{%d,%d},{%d,%d}}</string>
+            <key>textureRotated</key>
+            <false/>
+        </dict>
+`, xmlText(r.Name), offsetX, offsetY, frame.Dx(), frame.Dy(), r.Rect.Dx(), r.Rect.Dy(), frame.Min.X, frame.Min.Y, frame.Dx(), frame.Dy())
+	}
+	fmt.Fprintf(&plist, `    </dict>
+    <key>metadata</key>
+    <dict>
+        <key>format</key>
+        <integer>3</integer>
+        <key>pixelFormat</key>
+        <string>RGBA8888</string>
+        <key>premultiplyAlpha</key>
+        <false/>
+        <key>realTextureFileName</key>
+        <string>%s</string>
+        <key>size</key>
+        <string>{%d,%d}</string>
+        <key>textureFileName</key>
+        <string>%s</string>
+    </dict>
+</dict>
+</plist>
+`, image, e.Size.X, e.Size.Y, image)
+	return writeFile(filename, plist.Bytes(), 0644)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// codepoint is an entry of a code point list: a single code point or a sequence like an emoji
+// ZWJ sequence.
+type codepoint struct {
+	// Key is the upper case hex code points joined by "-", e.g. 1F468-200D-1F469.
+	Key string
+	// Name is the frame name, e.g. u1F468_200D_1F469.
+	Name string
+}
+
+// loadCodepoints reads a list of code points separated by white space. Every entry is a hex code
+// point, optionally prefixed by U+ or 0x, or a sequence of them joined by "-" or "_".
+func loadCodepoints(filename string) ([]codepoint, error) {
+	data, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		return nil, readErr
+	}
+	var codepoints []codepoint
+	for _, entry := range strings.Fields(string(data)) {
+		var parts []string
+		for _, part := range strings.FieldsFunc(entry, func(r rune) bool { return r == '-' || r == '_' }) {
+			hex := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(part), "U+"), "0X")
+			value, parseErr := strconv.ParseUint(hex, 16, 32)
+			if parseErr != nil || value > 0x10FFFF {
+				return nil, fmt.Errorf("invalid code point %q", entry)
+			}
+			parts = append(parts, fmt.Sprintf("%04X", value))
+		}
+		if len(parts) == 0 {
+			return nil, fmt.Errorf("invalid code point %q", entry)
+		}
+		codepoints = append(codepoints, codepoint{Key: strings.Join(parts, "-"), Name: "u" + strings.Join(parts, "_")})
+	}
+	return codepoints, nil
+}
+
+// codepointCells names the cells in reading order by the code points, like charmapCells.
+func codepointCells(grid []cell, codepoints []codepoint) []cell {
+	cells := make([]cell, 0, min(len(grid), len(codepoints)))
+	for i, c := range grid {
+		if i >= len(codepoints) {
+			break
+		}
+		c.Name = codepoints[i].Name
+		cells = append(cells, c)
+	}
+	return cells
+}
+
+// writeCodepointMap writes a JSON object mapping the key of every code point to the file name of
+// its frame, relative to the directory of filename. Mirrored variants are left out.
+func writeCodepointMap(filename string, codepoints []codepoint, files []frameFile) error {
+	keys := make(map[string]string, len(codepoints))
+	for _, c := range codepoints {
+		keys[c.Name] = c.Key
+	}
+	m := make(map[string]string)
+	for _, f := range files {
+		key, ok := keys[f.Name]
+		if !ok || f.Mirrored {
+			continue
+		}
+		rel, relErr := filepath.Rel(filepath.Dir(filename), f.Filename)
+		if relErr != nil {
+			return relErr
+		}
+		m[key] = filepath.ToSlash(rel)
+	}
+	data, marshalErr := json.MarshalIndent(m, "", "  ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return writeFile(filename, append(data, '\n'), 0644)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// countColors returns the number of distinct colors in the part r of img. All fully
+// transparent pixels count as one color.
+func countColors(img image.Image, r image.Rectangle) int {
+	colors := make(map[color.RGBA64]bool)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.RGBA64Model.Convert(img.At(x, y)).(color.RGBA64)
+			if c.A == 0 {
+				c = color.RGBA64{}
+			}
+			colors[c] = true
+		}
+	}
+	return len(colors)
+}
+
+// checkColors returns a description of every part of img using more than maxColors colors. If
+// blockSize is not 0, the limit applies to each blockSize x blockSize block of the frame instead
+// of the whole frame.
+func checkColors(img image.Image, maxColors, blockSize int) []string {
+	b := img.Bounds()
+	if blockSize == 0 {
+		if n := countColors(img, b); n > maxColors {
+			return []string{fmt.Sprintf("uses %d colors, more than %d", n, maxColors)}
+		}
+		return nil
+	}
+
+	var violations []string
+	for y := b.Min.Y; y < b.Max.Y; y += blockSize {
+		for x := b.Min.X; x < b.Max.X; x += blockSize {
+			block := image.Rect(x, y, x+blockSize, y+blockSize).Intersect(b)
+			if n := countColors(img, block); n > maxColors {
+				violations = append(violations, fmt.Sprintf("block at %d,%d uses %d colors, more than %d", x-b.Min.X, y-b.Min.Y, n, maxColors))
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// colorFlag is a color given on the command line as #rgb, #rrggbb or #rrggbbaa. Valid is set once
+// it has been parsed.
+type colorFlag struct {
+	Color color.NRGBA
+	Valid bool
+}
+
+func (c *colorFlag) String() string {
+	if !c.Valid {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.Color.R, c.Color.G, c.Color.B, c.Color.A)
+}
+
+func (c *colorFlag) Set(value string) error {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return fmt.Errorf("expected #rgb, #rrggbb or #rrggbbaa")
+	}
+	rgba, parseErr := strconv.ParseUint(hex, 16, 32)
+	if parseErr != nil {
+		return fmt.Errorf("expected #rgb, #rrggbb or #rrggbbaa")
+	}
+	*c = colorFlag{Color: color.NRGBA{R: uint8(rgba >> 24), G: uint8(rgba >> 16), B: uint8(rgba >> 8), A: uint8(rgba)}, Valid: true}
+	return nil
+}
+
+// color returns the color, or nil if none has been given.
+func (c *colorFlag) color() color.Color {
+	if !c.Valid {
+		return nil
+	}
+	return c.Color
+}
+
+// colorKeyFlag is a color given as #rrggbb[:tolerance] whose pixels become transparent. Pixels
+// also match if no channel differs by more than Tolerance.
+type colorKeyFlag struct {
+	colorFlag
+	Tolerance uint8
+}
+
+func (k *colorKeyFlag) String() string {
+	if k.Tolerance == 0 {
+		return k.colorFlag.String()
+	}
+	return fmt.Sprintf("%s:%d", k.colorFlag.String(), k.Tolerance)
+}
+
+func (k *colorKeyFlag) Set(value string) error {
+	hex, tolerance, hasTolerance := strings.Cut(value, ":")
+	if colorErr := k.colorFlag.Set(hex); colorErr != nil {
+		return colorErr
+	}
+	k.Tolerance = 0
+	if hasTolerance {
+		t, parseErr := strconv.ParseUint(tolerance, 10, 8)
+		if parseErr != nil {
+			return fmt.Errorf("expected a tolerance from 0 to 255 after the colon")
+		}
+		k.Tolerance = uint8(t)
+	}
+	return nil
+}
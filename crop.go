@@ -0,0 +1,46 @@
+package main
+
+import (
+	"image"
+
+	"github.com/hschendel/spritemap-explode/spritemapexplode"
+)
+
+// opaqueBounds returns the smallest rectangle containing all pixels of img that are not fully
+// transparent, or an empty rectangle if there are none.
+func opaqueBounds(img image.Image) image.Rectangle {
+	b := img.Bounds()
+	var r image.Rectangle
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, alpha := img.At(x, y).RGBA(); alpha != 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// trimImage crops img to its pixels that are not fully transparent. It returns the cropped image
+// and its bounds relative to the top left corner of img.
+func trimImage(img image.Image) (image.Image, image.Rectangle) {
+	b := img.Bounds()
+	r := opaqueBounds(img)
+	if r.Empty() {
+		return img, b.Sub(b.Min)
+	}
+	return spritemapexplode.SubImage(img, r), r.Sub(b.Min)
+}
+
+// cropSheet trims the fully transparent outer rows and columns of the sprite map if -autocrop is
+// set, so that the grid is computed from the remaining area.
+func (a *args) cropSheet(img SpriteMap) SpriteMap {
+	if !a.AutoCrop {
+		return img
+	}
+	r := opaqueBounds(img)
+	if r.Empty() {
+		return img
+	}
+	return img.SubImage(r).(SpriteMap)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cssClass returns name as a CSS class name, replacing characters that are not allowed.
+func cssClass(name string) string {
+	class := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	if class == "" || class[0] >= '0' && class[0] <= '9' || strings.HasPrefix(class, "--") {
+		class = "_" + class
+	}
+	return class
+}
+
+// writeCSS writes a stylesheet to <base>.css with one class per region, showing it as the
+// background of an element of the region's size.
+func writeCSS(e *sheetExport) error {
+	filename := e.Base + ".css"
+	var css strings.Builder
+	for _, r := range e.Regions {
+		fmt.Fprintf(&css, ".%s {\n  background: url(%q) no-repeat %dpx %dpx;\n  width: %dpx;\n  height: %dpx;\n}\n",
+			cssClass(r.Name), e.relImage(filename), -r.Rect.Min.X, -r.Rect.Min.Y, r.Rect.Dx(), r.Rect.Dy())
+	}
+	return writeFile(filename, []byte(css.String()), 0644)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// emptyThreshold is the -empty-threshold flag given as alpha[,pixels]: the highest opacity of a
+// pixel that still counts as transparent, and the number of other pixels an empty frame may have.
+type emptyThreshold struct {
+	Alpha  uint8
+	Pixels int
+}
+
+func (t *emptyThreshold) String() string {
+	if t.Pixels == 0 {
+		return strconv.Itoa(int(t.Alpha))
+	}
+	return fmt.Sprintf("%d,%d", t.Alpha, t.Pixels)
+}
+
+func (t *emptyThreshold) Set(value string) error {
+	alpha, pixels, hasPixels := strings.Cut(value, ",")
+	a, alphaErr := strconv.ParseUint(strings.TrimSpace(alpha), 10, 8)
+	if alphaErr != nil {
+		return fmt.Errorf("expected an alpha value from 0 to 255, optionally followed by ,<pixels>")
+	}
+	t.Alpha, t.Pixels = uint8(a), 0
+	if hasPixels {
+		p, pixelsErr := strconv.Atoi(strings.TrimSpace(pixels))
+		if pixelsErr != nil || p < 0 {
+			return fmt.Errorf("expected a pixel count of 0 or more after the comma")
+		}
+		t.Pixels = p
+	}
+	return nil
+}
+
+// frameEmpty reports whether img counts as an empty frame, taking -empty-threshold into account.
+func (a *args) frameEmpty(img image.Image) bool {
+	return a.options().FrameEmpty(img)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// excludeList is the comma separated or repeated list of -exclude patterns, matched against the
+// <row index>-<column index> of a cell and against its name.
+type excludeList []string
+
+func (l *excludeList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *excludeList) Set(value string) error {
+	for _, pattern := range strings.Split(value, ",") {
+		if _, matchErr := path.Match(pattern, ""); matchErr != nil {
+			return matchErr
+		}
+		*l = append(*l, pattern)
+	}
+	return nil
+}
+
+// excludes reports whether a pattern matches the cell.
+func (l excludeList) excludes(c cell) bool {
+	index := strconv.Itoa(c.Row) + "-" + strconv.Itoa(c.Column)
+	for _, pattern := range l {
+		if matched, _ := path.Match(pattern, index); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, c.Name); matched && c.Name != "" {
+			return true
+		}
+	}
+	return false
+}
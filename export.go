@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sheetRegion is a named frame of a sprite map described by an export. If the frame has been
+// trimmed, Trim is the visible part of Rect relative to its top left corner.
+type sheetRegion struct {
+	Name     string
+	Rect     image.Rectangle
+	Duration int
+	Trim     image.Rectangle
+}
+
+// trimmed returns if the region only keeps part of its rectangle.
+func (r sheetRegion) trimmed() bool {
+	return !r.Trim.Empty() && r.Trim != r.Rect.Sub(r.Rect.Min)
+}
+
+// frame returns the part of the sprite map holding the visible frame, which is the trimmed part
+// of Rect for trimmed regions.
+func (r sheetRegion) frame() image.Rectangle {
+	if r.trimmed() {
+		return r.Trim.Add(r.Rect.Min)
+	}
+	return r.Rect
+}
+
+// sheetExport describes the frames of a sprite map for game engines and loaders. Base is the
+// file name prefix of the written files, Image the file name of the sprite map. Pivot is the
+// anchor name of -pivot. If Data is set, the texturepacker export is written to it, e.g. the data
+// file named by a -tps project.
+type sheetExport struct {
+	Base    string
+	Image   string
+	Data    string
+	Size    image.Point
+	Pivot   string
+	Regions []sheetRegion
+}
+
+// relImage returns the file name of the sprite map relative to the directory of filename.
+func (e *sheetExport) relImage(filename string) string {
+	rel, relErr := filepath.Rel(filepath.Dir(filename), e.Image)
+	if relErr != nil {
+		return e.Image
+	}
+	return filepath.ToSlash(rel)
+}
+
+// exportFormats maps the supported values of -export to the functions writing them.
+var exportFormats = map[string]func(e *sheetExport) error{
+	"cocos":         writeCocos,
+	"css":           writeCSS,
+	"godot":         writeGodot,
+	"phaser3":       writePhaser3,
+	"texturepacker": writeTexturePacker,
+	"unity":         writeUnity,
+}
+
+func exportFormatNames() string {
+	names := make([]string, 0, len(exportFormats))
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// exportList collects the export formats given by repeated or comma separated -export flags.
+type exportList []string
+
+func (l *exportList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *exportList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if _, ok := exportFormats[name]; !ok {
+			return fmt.Errorf("unknown export format %s, supported are %s", name, exportFormatNames())
+		}
+		*l = append(*l, name)
+	}
+	return nil
+}
+
+// writeExports writes every export format of the list.
+func (l exportList) writeExports(e *sheetExport) error {
+	for _, name := range l {
+		if exportErr := exportFormats[name](e); exportErr != nil {
+			return fmt.Errorf("%s: %v", name, exportErr)
+		}
+	}
+	return nil
+}
+
+// newFrameExport describes the written frames, without mirrored copies, as regions of the sprite
+// map file source they were cut from, named after their files. bounds are those of the decoded
+// file, so that the regions stay in file coordinates when -autocrop has cut the sheet. The trim of
+// -trim is kept if the frame had the size of its cell before, as it is not in sheet coordinates
+// after -scale or transformations changing the size.
+func newFrameExport(base string, source string, pivot string, bounds image.Rectangle, files []frameFile) *sheetExport {
+	e := &sheetExport{Base: base, Image: source, Size: bounds.Size(), Pivot: pivot}
+	for _, f := range files {
+		if f.Mirrored {
+			continue
+		}
+		name := path.Base(f.Filename)
+		r := sheetRegion{Name: strings.TrimSuffix(name, path.Ext(name)), Rect: f.Rect.Sub(bounds.Min), Duration: f.Duration}
+		if f.Untrimmed == f.Rect.Size() {
+			r.Trim = f.Trim
+		}
+		e.Regions = append(e.Regions, r)
+	}
+	return e
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFrameExportAutoCrop(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 52, 36))
+	for y := 10; y < 26; y++ {
+		for x := 10; x < 42; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	a := &args{AutoCrop: true, FrameWidth: 16, FrameHeight: 16}
+	cells, gridErr := a.gridCells(a.cropSheet(img))
+	if gridErr != nil {
+		t.Fatal(gridErr)
+	}
+	var files []frameFile
+	for _, c := range cells {
+		files = append(files, frameFile{Filename: "sheet-0-0.png", Row: c.Row, Column: c.Column, Rect: c.Rect})
+	}
+
+	e := newFrameExport("sheet", "sheet.png", "center", img.Bounds(), files)
+	if e.Size != image.Pt(52, 36) {
+		t.Errorf("size is %v, expected the size of the file 52x36", e.Size)
+	}
+	expected := []image.Rectangle{image.Rect(10, 10, 26, 26), image.Rect(26, 10, 42, 26)}
+	if len(e.Regions) != len(expected) {
+		t.Fatalf("got %d regions, expected %d", len(e.Regions), len(expected))
+	}
+	for i, r := range e.Regions {
+		if r.Rect != expected[i] {
+			t.Errorf("region %d is %v, expected %v", i, r.Rect, expected[i])
+		}
+	}
+}
+
+func TestTexturePackerTrim(t *testing.T) {
+	files := []frameFile{
+		{Filename: "sheet-0-0.png", Rect: image.Rect(16, 0, 32, 16), Untrimmed: image.Pt(16, 16), Trim: image.Rect(3, 2, 10, 12)},
+		{Filename: "sheet-0-1.png", Rect: image.Rect(32, 0, 48, 16), Untrimmed: image.Pt(32, 32), Trim: image.Rect(6, 4, 20, 24)},
+	}
+	atlas := newTexturePackerAtlas("sheet-texturepacker.json", newFrameExport("sheet", "sheet.png", "bottom", image.Rect(0, 0, 48, 16), files))
+
+	trimmed := atlas.Frames["sheet-0-0"]
+	if !trimmed.Trimmed || trimmed.Frame != (texturePackerRect{X: 19, Y: 2, W: 7, H: 10}) ||
+		trimmed.SpriteSourceSize != (texturePackerRect{X: 3, Y: 2, W: 7, H: 10}) || trimmed.SourceSize != (texturePackerSize{W: 16, H: 16}) {
+		t.Errorf("trimmed frame is %+v", trimmed)
+	}
+	if trimmed.Pivot.X != 0.5 || trimmed.Pivot.Y != 1 {
+		t.Errorf("pivot is %v,%v, expected the bottom center 0.5,1", trimmed.Pivot.X, trimmed.Pivot.Y)
+	}
+
+	// the trim of the scaled frame is not in sheet coordinates
+	scaled := atlas.Frames["sheet-0-1"]
+	if scaled.Trimmed || scaled.Frame != (texturePackerRect{X: 32, Y: 0, W: 16, H: 16}) {
+		t.Errorf("scaled frame is %+v, expected it untrimmed", scaled)
+	}
+}
+
+func TestUnityQuotesNames(t *testing.T) {
+	e := &sheetExport{Image: filepath.Join(t.TempDir(), "sheet.png"), Size: image.Pt(16, 16),
+		Regions: []sheetRegion{{Name: `walk: "left" #1`, Rect: image.Rect(0, 0, 16, 16)}}}
+	if writeErr := writeUnity(e); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	meta, readErr := os.ReadFile(e.Image + ".meta")
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if expected := `name: "walk: \"left\" #1"`; !strings.Contains(string(meta), expected) {
+		t.Errorf("%s does not contain %s", meta, expected)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// cellIndex is a row,column pair given on the command line. Valid is set once it has been parsed.
+type cellIndex struct {
+	Row    int
+	Column int
+	Valid  bool
+}
+
+func (i *cellIndex) String() string {
+	if !i.Valid {
+		return ""
+	}
+	return strconv.Itoa(i.Row) + "," + strconv.Itoa(i.Column)
+}
+
+func (i *cellIndex) Set(value string) error {
+	rowText, columnText, ok := strings.Cut(value, ",")
+	if !ok {
+		return fmt.Errorf("expected row,column")
+	}
+	row, rowErr := strconv.Atoi(strings.TrimSpace(rowText))
+	if rowErr != nil {
+		return rowErr
+	}
+	column, columnErr := strconv.Atoi(strings.TrimSpace(columnText))
+	if columnErr != nil {
+		return columnErr
+	}
+	if row < 0 || column < 0 {
+		return fmt.Errorf("row and column must not be negative")
+	}
+	*i = cellIndex{Row: row, Column: column, Valid: true}
+	return nil
+}
+
+// extractFrame writes the frame of the sprite map at the -extract index to the -o file, in the
+// format of its extension, or as PNG to stdout if that is "-". The cells are those of loadSheet,
+// so that the index refers to the same frame as when exploding.
+func extractFrame(a *args, sheet loadedSheet) error {
+	for _, c := range sheet.Cells {
+		if c.Row != a.Extract.Row || c.Column != a.Extract.Column {
+			continue
+		}
+		frame := sheet.SpriteMap.SubImage(c.Rect)
+		if a.Output == "-" {
+			return png.Encode(os.Stdout, frame)
+		}
+		encode, ok := imageEncoders[strings.ToLower(path.Ext(a.Output))]
+		if !ok {
+			return fmt.Errorf("unknown image format %s", path.Ext(a.Output))
+		}
+		outFile, createErr := createFile(a.Output)
+		if createErr != nil {
+			return createErr
+		}
+		encodeErr := encode(outFile, frame)
+		if closeErr := outFile.Close(); encodeErr == nil {
+			encodeErr = closeErr
+		}
+		return encodeErr
+	}
+	return fmt.Errorf("frame %d-%d is outside of the sprite map", a.Extract.Row, a.Extract.Column)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// imageEncoder writes an image in one file format.
+type imageEncoder func(w io.Writer, img image.Image) error
+
+// imageEncoders maps the lower case file extensions that can be written to their encoders. Other
+// extensions are written as PNG.
+var imageEncoders = map[string]imageEncoder{
+	".png":  png.Encode,
+	".gif":  func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) },
+	".bmp":  bmp.Encode,
+	".jpg":  encodeJPEG,
+	".jpeg": encodeJPEG,
+	".webp": func(w io.Writer, img image.Image) error { return nativewebp.Encode(w, img, nil) },
+	".tif":  encodeTIFF,
+	".tiff": encodeTIFF,
+}
+
+func encodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 95})
+}
+
+// encodeTIFF writes img as TIFF, keeping 16 bits per channel if img has them. The image is copied
+// first because the TIFF encoder cannot handle sub images.
+func encodeTIFF(w io.Writer, img image.Image) error {
+	var copied draw.Image
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model:
+		copied = image.NewNRGBA64(image.Rectangle{Max: img.Bounds().Size()})
+	default:
+		copied = image.NewNRGBA(image.Rectangle{Max: img.Bounds().Size()})
+	}
+	draw.Draw(copied, copied.Bounds(), img, img.Bounds().Min, draw.Src)
+	return tiff.Encode(w, copied, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+}
+
+// imageFormatExtensions maps the supported values of -format to their file extensions.
+var imageFormatExtensions = map[string]string{
+	"png":  ".png",
+	"gif":  ".gif",
+	"bmp":  ".bmp",
+	"jpeg": ".jpg",
+	"jpg":  ".jpg",
+	"webp": ".webp",
+	"tiff": ".tif",
+}
+
+// deepExtensions are the frame file extensions whose encoders write 16 bits per channel.
+var deepExtensions = map[string]bool{
+	".png": true,
+	".tif": true,
+}
+
+// needs8Bit reports whether the frames have to be converted to 8 bits per channel before they are
+// written, because of -preserve-depth=false, the frame format or the shared palette.
+func (a *args) needs8Bit() bool {
+	return !a.PreserveDepth || !deepExtensions[a.frameExtension()] || a.palette != nil
+}
+
+func imageFormatNames() string {
+	names := make([]string, 0, len(imageFormatExtensions))
+	for name := range imageFormatExtensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// frameExtension returns the file extension of the frames in the -format.
+func (a *args) frameExtension() string {
+	return imageFormatExtensions[a.Format]
+}
@@ -0,0 +1,12 @@
+module github.com/hschendel/spritemap-explode
+
+go 1.26.0
+
+require (
+	github.com/HugoSmits86/nativewebp v1.3.0
+	go.starlark.net v0.0.0-20260908191801-89a6a09411d5
+	golang.org/x/image v0.24.0
+	golang.org/x/term v0.46.0
+)
+
+require golang.org/x/sys v0.48.0 // indirect
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// godotAtlasTexture is a Godot 4 AtlasTexture resource for one region of a sprite map.
+const godotAtlasTexture = `[gd_resource type="AtlasTexture" load_steps=2 format=3]
+
+[ext_resource type="Texture2D" path=%q id="1"]
+
+[resource]
+atlas = ExtResource("1")
+region = Rect2(%d, %d, %d, %d)
+`
+
+// writeGodot writes a Godot AtlasTexture resource <name>.tres for every region into the
+// directory of the base, referencing the sprite map by its relative path.
+func writeGodot(e *sheetExport) error {
+	for _, r := range e.Regions {
+		filename := filepath.Join(filepath.Dir(e.Base), r.Name+".tres")
+		resource := fmt.Sprintf(godotAtlasTexture, e.relImage(filename), r.Rect.Min.X, r.Rect.Min.Y, r.Rect.Dx(), r.Rect.Dy())
+		if writeErr := writeFile(filename, []byte(resource), 0644); writeErr != nil {
+			return writeErr
+		}
+	}
+	return nil
+}
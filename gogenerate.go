@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goPackageName derives a valid Go package name from the directory name.
+func goPackageName(dir string) string {
+	var name strings.Builder
+	for _, r := range strings.ToLower(filepath.Base(filepath.Clean(dir))) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			name.WriteRune(r)
+		}
+	}
+	if name.Len() == 0 || unicode.IsDigit(rune(name.String()[0])) {
+		return "frames" + name.String()
+	}
+	return name.String()
+}
+
+// goFrameKey returns the FrameKey literal of a frame. Frames of rows without an animation name are
+// keyed by the row index.
+func goFrameKey(f frameFile) string {
+	animation := f.Animation
+	if animation == "" {
+		animation = strconv.Itoa(f.Row)
+	}
+	return fmt.Sprintf("{Animation: %q, Frame: %d, Mirror: %q}", animation, f.Column, f.Mirror)
+}
+
+// writeGoPackage generates dir/frames.go embedding the given frame files. It fails if two frames
+// have the same FrameKey, as one of them could not be looked up.
+func writeGoPackage(dir string, files []frameFile) error {
+	var src bytes.Buffer
+	fmt.Fprintln(&src, "// Code generated by spritemap-explode; DO NOT EDIT.")
+	fmt.Fprintln(&src)
+	fmt.Fprintf(&src, "package %s\n\n", goPackageName(dir))
+	fmt.Fprintln(&src, "import (")
+	fmt.Fprintln(&src, `"embed"`)
+	fmt.Fprintln(&src, `"time"`)
+	fmt.Fprintln(&src, ")")
+	fmt.Fprintln(&src)
+	fmt.Fprintln(&src, "// FrameKey identifies a frame by its animation, frame within the animation and mirror variant,")
+	fmt.Fprintln(&src, "// which is empty unless the frames have been mirrored. Animations are named by -animations,")
+	fmt.Fprintln(&src, "// -row-names or the tags of Aseprite files, otherwise they are the row index, like \"0\".")
+	fmt.Fprintln(&src, "type FrameKey struct {")
+	fmt.Fprintln(&src, "Animation string")
+	fmt.Fprintln(&src, "Frame int")
+	fmt.Fprintln(&src, "Mirror string")
+	fmt.Fprintln(&src, "}")
+	fmt.Fprintln(&src)
+
+	names := make([]string, len(files))
+	keys := make([]string, len(files))
+	keyFiles := make(map[string]string, len(files))
+	for i, f := range files {
+		keys[i] = goFrameKey(f)
+		if other, taken := keyFiles[keys[i]]; taken {
+			return fmt.Errorf("frame files %s and %s have the same key %s", other, f.Filename, keys[i])
+		}
+		keyFiles[keys[i]] = f.Filename
+		rel, relErr := filepath.Rel(dir, f.Filename)
+		if relErr != nil {
+			return relErr
+		}
+		names[i] = filepath.ToSlash(rel)
+		if strings.HasPrefix(names[i], "../") {
+			return fmt.Errorf("frame file %s is outside of the package directory", f.Filename)
+		}
+	}
+
+	fmt.Fprintln(&src, "// FS contains the frame files.")
+	fmt.Fprintln(&src, "//")
+	for _, name := range names {
+		fmt.Fprintf(&src, "//go:embed %s\n", strconv.Quote(name))
+	}
+	fmt.Fprintln(&src, "var FS embed.FS")
+	fmt.Fprintln(&src)
+
+	fmt.Fprintln(&src, "// Frames maps every frame to its file name in FS.")
+	fmt.Fprintln(&src, "var Frames = map[FrameKey]string{")
+	for i := range files {
+		fmt.Fprintf(&src, "%s: %q,\n", keys[i], names[i])
+	}
+	fmt.Fprintln(&src, "}")
+	fmt.Fprintln(&src)
+
+	fmt.Fprintln(&src, "// Durations maps every frame to its display duration.")
+	fmt.Fprintln(&src, "var Durations = map[FrameKey]time.Duration{")
+	for i, f := range files {
+		fmt.Fprintf(&src, "%s: %d * time.Millisecond,\n", keys[i], f.Duration)
+	}
+	fmt.Fprintln(&src, "}")
+
+	formatted, formatErr := format.Source(src.Bytes())
+	if formatErr != nil {
+		return formatErr
+	}
+	return writeFile(path.Join(dir, "frames.go"), formatted, 0644)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// compareImages returns a description of how img differs from golden, or an empty string if both
+// have the same size and pixels.
+func compareImages(img image.Image, golden image.Image) string {
+	size, goldenSize := img.Bounds().Size(), golden.Bounds().Size()
+	if size != goldenSize {
+		return fmt.Sprintf("size is %dx%d, expected %dx%d", size.X, size.Y, goldenSize.X, goldenSize.Y)
+	}
+	differing := 0
+	var first image.Point
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(img.Bounds().Min.X+x, img.Bounds().Min.Y+y))
+			g := color.NRGBAModel.Convert(golden.At(golden.Bounds().Min.X+x, golden.Bounds().Min.Y+y))
+			if c != g {
+				if differing == 0 {
+					first = image.Pt(x, y)
+				}
+				differing++
+			}
+		}
+	}
+	if differing == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d pixels differ, the first at %d,%d", differing, first.X, first.Y)
+}
+
+// encodedImage returns img as it is read back after writing it to filename, so that frames of lossy
+// formats like JPEG compare equal to the files written before.
+func encodedImage(img image.Image, filename string) (image.Image, error) {
+	encode, ok := imageEncoders[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return img, nil
+	}
+	var encoded bytes.Buffer
+	if encodeErr := encode(&encoded, img); encodeErr != nil {
+		return nil, encodeErr
+	}
+	decoded, _, decodeErr := decodeImage(&encoded, filename)
+	return decoded, decodeErr
+}
+
+// compareGolden compares the frames, which have been exploded without writing them, pixel by pixel
+// against the files of the same name in dir, after encoding them in the format of their extension.
+// Frame names are relative to frameDir. Every mismatch, missing frame and frame missing from the
+// output is reported to w, and their number is returned.
+func compareGolden(w io.Writer, dir string, frameDir string, files []frameFile) (int, error) {
+	mismatches := 0
+	produced := make(map[string]bool, len(files))
+	for _, f := range files {
+		rel, relErr := filepath.Rel(frameDir, f.Filename)
+		if relErr != nil {
+			return mismatches, relErr
+		}
+		produced[filepath.ToSlash(rel)] = true
+
+		file, openErr := os.Open(filepath.Join(dir, rel))
+		if os.IsNotExist(openErr) {
+			fmt.Fprintln(w, rel+": missing in", dir)
+			mismatches++
+			continue
+		} else if openErr != nil {
+			return mismatches, openErr
+		}
+		golden, _, decodeErr := image.Decode(file)
+		file.Close()
+		if decodeErr != nil {
+			return mismatches, fmt.Errorf("cannot decode %s: %v", filepath.Join(dir, rel), decodeErr)
+		}
+		img, encodeErr := encodedImage(f.Image, f.Filename)
+		if encodeErr != nil {
+			return mismatches, fmt.Errorf("cannot encode %s: %v", rel, encodeErr)
+		}
+		if difference := compareImages(img, golden); difference != "" {
+			fmt.Fprintln(w, rel+":", difference)
+			mismatches++
+		}
+	}
+
+	extensions := make(map[string]bool)
+	for rel := range produced {
+		extensions[strings.ToLower(filepath.Ext(rel))] = true
+	}
+	walkErr := filepath.WalkDir(dir, func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !extensions[strings.ToLower(filepath.Ext(filename))] {
+			return err
+		}
+		rel, relErr := filepath.Rel(dir, filename)
+		if relErr != nil {
+			return relErr
+		}
+		if !produced[filepath.ToSlash(rel)] {
+			fmt.Fprintln(w, rel+": not produced")
+			mismatches++
+		}
+		return nil
+	})
+	return mismatches, walkErr
+}
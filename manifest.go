@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"path/filepath"
+)
+
+// manifestRect is a rectangle of the sprite map in a manifest.
+type manifestRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// manifestPoint is an offset in a manifest.
+type manifestPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// manifestSize is the size of a frame in a manifest.
+type manifestSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// manifestFrame describes one written frame, or a cell of the sprite map that was skipped, in a
+// manifest. Filenames are relative to the manifest. Trimmed frames have the offset of the written
+// part within the frame of SourceSize.
+type manifestFrame struct {
+	Filename   string         `json:"filename,omitempty"`
+	Rect       manifestRect   `json:"rect"`
+	Row        int            `json:"row"`
+	Column     int            `json:"column"`
+	Name       string         `json:"name,omitempty"`
+	Mirror     string         `json:"mirror,omitempty"`
+	Mirrored   bool           `json:"mirrored"`
+	Duration   int            `json:"duration,omitempty"`
+	Skipped    bool           `json:"skipped"`
+	Empty      bool           `json:"empty"`
+	Trimmed    bool           `json:"trimmed"`
+	Offset     *manifestPoint `json:"offset,omitempty"`
+	SourceSize *manifestSize  `json:"sourceSize,omitempty"`
+}
+
+// manifest is the JSON document written by -manifest.
+type manifest struct {
+	Source string          `json:"source"`
+	Frames []manifestFrame `json:"frames"`
+}
+
+// newManifest lists the written frames of every cell in the order of the cells. Cells without
+// written frames are listed as skipped, and whether they are empty is told by the empty function.
+func newManifest(filename string, source string, img SpriteMap, cells []cell, files []frameFile, empty func(image.Image) bool) (manifest, error) {
+	type cellKey struct {
+		Rect        image.Rectangle
+		Row, Column int
+	}
+	written := make(map[cellKey][]frameFile, len(files))
+	for _, f := range files {
+		key := cellKey{Rect: f.Rect, Row: f.Row, Column: f.Column}
+		written[key] = append(written[key], f)
+	}
+	m := manifest{Source: source, Frames: []manifestFrame{}}
+	for _, c := range cells {
+		rect := manifestRect{X: c.Rect.Min.X, Y: c.Rect.Min.Y, W: c.Rect.Dx(), H: c.Rect.Dy()}
+		frames, ok := written[cellKey{Rect: c.Rect, Row: c.Row, Column: c.Column}]
+		if !ok {
+			m.Frames = append(m.Frames, manifestFrame{Rect: rect, Row: c.Row, Column: c.Column, Name: c.Name, Skipped: true, Empty: empty(img.SubImage(c.Rect))})
+			continue
+		}
+		for _, f := range frames {
+			rel, relErr := filepath.Rel(filepath.Dir(filename), f.Filename)
+			if relErr != nil {
+				return m, relErr
+			}
+			frame := manifestFrame{Filename: filepath.ToSlash(rel), Rect: rect, Row: f.Row, Column: f.Column,
+				Name: f.Name, Mirror: f.Mirror, Mirrored: f.Mirrored, Duration: f.Duration}
+			if f.Trim != (image.Rectangle{}) {
+				frame.Trimmed = true
+				frame.Offset = &manifestPoint{X: f.Trim.Min.X, Y: f.Trim.Min.Y}
+				frame.SourceSize = &manifestSize{W: f.Untrimmed.X, H: f.Untrimmed.Y}
+			}
+			m.Frames = append(m.Frames, frame)
+		}
+	}
+	return m, nil
+}
+
+// writeManifest writes a JSON manifest of the written and skipped frames to filename.
+func writeManifest(filename string, source string, img SpriteMap, cells []cell, files []frameFile, empty func(image.Image) bool) error {
+	m, manifestErr := newManifest(filename, source, img, cells, files, empty)
+	if manifestErr != nil {
+		return manifestErr
+	}
+	data, marshalErr := json.MarshalIndent(m, "", "  ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return writeFile(filename, append(data, '\n'), 0644)
+}
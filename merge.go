@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+)
+
+// loadMergedSheets decodes all sprite maps given on the command line and stacks them vertically
+// into one, so that the row indices of every sheet's grid continue where the previous sheet's
+// ended and all frames share one numbering space.
+func (a *args) loadMergedSheets() (SpriteMap, []cell, error) {
+	var sheets []SpriteMap
+	size := image.Point{}
+	for _, filename := range a.Filenames {
+		file, openErr := os.Open(filename)
+		if openErr != nil {
+			return nil, nil, openErr
+		}
+		img, imageFormat, decodeErr := decodeImage(file, filename)
+		file.Close()
+		if decodeErr != nil {
+			return nil, nil, fmt.Errorf("cannot decode %s: %v", filename, decodeErr)
+		}
+		sheet, ok := img.(SpriteMap)
+		if !ok {
+			return nil, nil, fmt.Errorf("image format %s of %s does not support extracting sub-images", imageFormat, filename)
+		}
+		sheet = a.cropSheet(sheet)
+		sheets = append(sheets, sheet)
+		size.X = max(size.X, sheet.Bounds().Dx())
+		size.Y += sheet.Bounds().Dy()
+	}
+
+	merged := image.NewNRGBA(image.Rectangle{Max: size})
+	var cells []cell
+	y, rowOffset := 0, 0
+	for _, sheet := range sheets {
+		b := sheet.Bounds()
+		draw.Draw(merged, image.Rect(0, y, b.Dx(), y+b.Dy()), sheet, b.Min, draw.Src)
+		grid, gridErr := a.gridCells(sheet)
+		if gridErr != nil {
+			return nil, nil, gridErr
+		}
+		rows := 0
+		for _, c := range grid {
+			c.Rect = c.Rect.Sub(b.Min).Add(image.Pt(0, y))
+			c.Row += rowOffset
+			rows = max(rows, c.Row+1-rowOffset)
+			cells = append(cells, c)
+		}
+		y += b.Dy()
+		rowOffset += rows
+	}
+	return merged, cells, nil
+}
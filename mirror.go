@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// mirrorPositions are the supported values of -mirror-position.
+var mirrorPositions = []string{"before", "after", "dir"}
+
+// tokenMap renames the mirror variant tokens in file names, given as from=to pairs separated by
+// commas.
+type tokenMap map[string]string
+
+func (m *tokenMap) String() string {
+	var pairs []string
+	for from, to := range *m {
+		pairs = append(pairs, from+"="+to)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m *tokenMap) Set(value string) error {
+	if *m == nil {
+		*m = make(tokenMap)
+	}
+	for _, pair := range strings.Split(value, ",") {
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || from == "" || to == "" || strings.ContainsAny(to, "/\\") {
+			return fmt.Errorf("expected from=to pairs separated by commas")
+		}
+		(*m)[from] = to
+	}
+	return nil
+}
+
+// frameFilename returns the file name of a frame from the prefix, the index part, which may be
+// empty, and the mirror token, which is placed according to -mirror-position.
+func (a *args) frameFilename(prefix string, index string, mirror string) string {
+	if mirror != "" && a.MirrorPosition == "dir" {
+		prefix = path.Join(path.Dir(prefix), mirror, path.Base(prefix))
+	}
+	parts := []string{prefix}
+	if mirror != "" && a.MirrorPosition == "before" {
+		parts = append(parts, mirror)
+	}
+	if index != "" {
+		parts = append(parts, index)
+	}
+	if mirror != "" && a.MirrorPosition == "after" {
+		parts = append(parts, mirror)
+	}
+	return strings.Join(parts, "-") + a.frameExtension()
+}
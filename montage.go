@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"golang.org/x/image/font/basicfont"
+)
+
+var (
+	montageBackground = color.NRGBA{R: 0x30, G: 0x30, B: 0x30, A: 255}
+	montageLabelColor = color.NRGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 255}
+)
+
+// dirFrame is an image file read from a directory of frames.
+type dirFrame struct {
+	Name  string
+	Image image.Image
+}
+
+// readFrameDir decodes all images in dir in the order of their file names. Files that are
+// no images are ignored.
+func readFrameDir(dir string) ([]dirFrame, error) {
+	entries, readErr := os.ReadDir(dir)
+	if readErr != nil {
+		return nil, readErr
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var frames []dirFrame
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		file, openErr := os.Open(filepath.Join(dir, entry.Name()))
+		if openErr != nil {
+			return nil, openErr
+		}
+		img, _, decodeErr := decodeImage(file, entry.Name())
+		file.Close()
+		if decodeErr != nil {
+			continue
+		}
+		frames = append(frames, dirFrame{Name: entry.Name(), Image: img})
+	}
+	return frames, nil
+}
+
+// montage lays out the frames on a contact sheet with the given number of columns, or roughly
+// square if columns is 0. Every frame is labeled with its index and file name.
+func montage(frames []dirFrame, columns int) image.Image {
+	if columns == 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(frames)))))
+	}
+	columns = max(min(columns, len(frames)), 1)
+	rows := (len(frames) + columns - 1) / columns
+
+	face := basicfont.Face7x13
+	labelHeight := 2*face.Height + 4
+	cellWidth, cellHeight := 0, 0
+	for i, f := range frames {
+		label := strconv.Itoa(i) + " " + f.Name
+		cellWidth = max(cellWidth, f.Image.Bounds().Dx(), len(label)*face.Advance+4)
+		cellHeight = max(cellHeight, f.Image.Bounds().Dy())
+	}
+	cellWidth += 8
+	cellHeight += labelHeight + 8
+
+	sheet := image.NewNRGBA(image.Rect(0, 0, columns*cellWidth, rows*cellHeight))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(montageBackground), image.Point{}, draw.Src)
+	for i, f := range frames {
+		origin := image.Pt(i%columns*cellWidth+4, i/columns*cellHeight+4)
+		b := f.Image.Bounds()
+		draw.Draw(sheet, image.Rectangle{Min: origin, Max: origin.Add(b.Size())}, f.Image, b.Min, draw.Over)
+		labelOrigin := origin.Add(image.Pt(0, cellHeight-8-labelHeight))
+		drawLabel(sheet, labelOrigin, strconv.Itoa(i), montageLabelColor)
+		drawLabel(sheet, labelOrigin.Add(image.Pt(0, face.Height)), f.Name, montageLabelColor)
+	}
+	return sheet
+}
+
+// writeMontage writes a contact sheet of the images in dir to filename.
+func writeMontage(dir string, filename string, columns int) error {
+	frames, readErr := readFrameDir(dir)
+	if readErr != nil {
+		return readErr
+	}
+	if len(frames) == 0 {
+		return fmt.Errorf("no images found in %s", dir)
+	}
+	if !saveImage(montage(frames, columns), filename) {
+		return fmt.Errorf("cannot write %s", filename)
+	}
+	return nil
+}
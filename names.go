@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// frameNames are the names of -names, given per cell as row,column or as the index of the cell in
+// reading order, both counted from 0.
+type frameNames struct {
+	ByCell  map[image.Point]string
+	ByIndex map[int]string
+}
+
+// loadFrameNames reads a CSV file with lines of row,column,name or index,name. Lines starting with
+// # are comments.
+func loadFrameNames(filename string) (*frameNames, error) {
+	file, openErr := os.Open(filename)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	names := &frameNames{ByCell: make(map[image.Point]string), ByIndex: make(map[int]string)}
+	for {
+		record, readErr := r.Read()
+		if readErr == io.EOF {
+			return names, nil
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+		line, _ := r.FieldPos(0)
+		indices := make([]int, len(record)-1)
+		for i := range indices {
+			index, atoiErr := strconv.Atoi(strings.TrimSpace(record[i]))
+			if atoiErr != nil || index < 0 {
+				return nil, fmt.Errorf("line %d: %q is not an index", line, record[i])
+			}
+			indices[i] = index
+		}
+		name := strings.TrimSpace(record[len(record)-1])
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("line %d: missing name", line)
+		case len(indices) == 1:
+			names.ByIndex[indices[0]] = name
+		case len(indices) == 2:
+			names.ByCell[image.Pt(indices[1], indices[0])] = name
+		default:
+			return nil, fmt.Errorf("line %d: expected row,column,name or index,name", line)
+		}
+	}
+}
+
+// cells names the cells found in the file, preferring the name given by row and column over the
+// one given by the index.
+func (n *frameNames) cells(cells []cell) []cell {
+	named := make([]cell, len(cells))
+	for i, c := range cells {
+		if name, ok := n.ByCell[image.Pt(c.Column, c.Row)]; ok {
+			c.Name = name
+		} else if name, ok := n.ByIndex[i]; ok {
+			c.Name = name
+		}
+		named[i] = c
+	}
+	return named
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path"
+	"strings"
+	"text/template"
+)
+
+// frameNameData is passed to the -name-template of a frame.
+type frameNameData struct {
+	Row    int
+	Col    int
+	Index  int
+	Mirror string
+	Name   string
+	Base   string
+}
+
+// parseNameTemplate parses the -name-template flag.
+func parseNameTemplate(text string) (*template.Template, error) {
+	return template.New("name").Parse(text)
+}
+
+// templateFilename returns the file name of a frame from the -name-template, relative to the
+// directory of the prefix.
+func (a *args) templateFilename(data frameNameData) (string, error) {
+	var name strings.Builder
+	if executeErr := a.nameTemplate.Execute(&name, data); executeErr != nil {
+		return "", executeErr
+	}
+	return path.Join(path.Dir(a.Prefix), name.String()) + a.frameExtension(), nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	overlayGridColor  = color.NRGBA{R: 255, G: 0, B: 255, A: 255}
+	overlayEmptyColor = color.NRGBA{R: 0, G: 0, B: 0, A: 96}
+	overlayLabelColor = color.NRGBA{R: 255, G: 255, B: 0, A: 255}
+
+	overlayExportedColor = color.NRGBA{R: 0, G: 255, B: 0, A: 64}
+	overlaySkippedColor  = color.NRGBA{R: 255, G: 0, B: 0, A: 128}
+)
+
+// drawRectOutline draws a one pixel wide outline along the inside of r.
+func drawRectOutline(dst draw.Image, r image.Rectangle, c color.Color) {
+	for x := r.Min.X; x < r.Max.X; x++ {
+		dst.Set(x, r.Min.Y, c)
+		dst.Set(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		dst.Set(r.Min.X, y, c)
+		dst.Set(r.Max.X-1, y, c)
+	}
+}
+
+// drawLabel draws text with its top left corner at p.
+func drawLabel(dst draw.Image, p image.Point, text string, c color.Color) {
+	face := basicfont.Face7x13
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(p.X+2, p.Y+2+face.Ascent),
+	}
+	d.DrawString(text)
+}
+
+// cellLabel returns the index of the cell as used in the frame file names.
+func cellLabel(c cell) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return strconv.Itoa(c.Row) + "-" + strconv.Itoa(c.Column)
+}
+
+// debugOverlay renders the sprite map with the outline and index of every cell drawn on top and
+// empty cells shaded, to check whether the grid arguments are right.
+func debugOverlay(img SpriteMap, cells []cell, empty func(image.Image) bool) image.Image {
+	overlay := image.NewNRGBA(img.Bounds())
+	draw.Draw(overlay, overlay.Bounds(), img, img.Bounds().Min, draw.Src)
+	for _, c := range cells {
+		if empty(img.SubImage(c.Rect)) {
+			draw.Draw(overlay, c.Rect, image.NewUniform(overlayEmptyColor), image.Point{}, draw.Over)
+		}
+		drawRectOutline(overlay, c.Rect, overlayGridColor)
+		drawLabel(overlay, c.Rect.Min, cellLabel(c), overlayLabelColor)
+	}
+	return overlay
+}
+
+// skipOverlay renders the sprite map with every cell that has been written tinted green and
+// every cell that has been skipped tinted red.
+func skipOverlay(img SpriteMap, cells []cell, files []frameFile) image.Image {
+	written := make(map[image.Rectangle]bool, len(files))
+	for _, f := range files {
+		written[f.Rect] = true
+	}
+	overlay := image.NewNRGBA(img.Bounds())
+	draw.Draw(overlay, overlay.Bounds(), img, img.Bounds().Min, draw.Src)
+	for _, c := range cells {
+		tint := overlaySkippedColor
+		if written[c.Rect] {
+			tint = overlayExportedColor
+		}
+		draw.Draw(overlay, c.Rect, image.NewUniform(tint), image.Point{}, draw.Over)
+	}
+	return overlay
+}
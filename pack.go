@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pack lays out the frames in reading order on a sprite map with the given number of columns, or
+// roughly square if columns is 0, with every frame in the top left corner of a cell of the size of
+// the largest frame and padding pixels between the cells. If size is not zero, the sprite map has
+// that size. It returns the sprite map and the rectangle of every frame.
+func pack(frames []dirFrame, columns int, padding int, size image.Point) (image.Image, []image.Rectangle, error) {
+	if columns == 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(frames)))))
+	}
+	columns = max(min(columns, len(frames)), 1)
+	rows := (len(frames) + columns - 1) / columns
+
+	var cellSize image.Point
+	for _, f := range frames {
+		cellSize.X = max(cellSize.X, f.Image.Bounds().Dx())
+		cellSize.Y = max(cellSize.Y, f.Image.Bounds().Dy())
+	}
+	needed := image.Pt(columns*cellSize.X+(columns-1)*padding, rows*cellSize.Y+(rows-1)*padding)
+	if size == (image.Point{}) {
+		size = needed
+	} else if needed.X > size.X || needed.Y > size.Y {
+		return nil, nil, fmt.Errorf("%d frames of %dx%d need %dx%d pixels", len(frames), cellSize.X, cellSize.Y, needed.X, needed.Y)
+	}
+
+	sheet := image.NewNRGBA(image.Rectangle{Max: size})
+	rects := make([]image.Rectangle, len(frames))
+	for i, f := range frames {
+		origin := image.Pt(i%columns*(cellSize.X+padding), i/columns*(cellSize.Y+padding))
+		b := f.Image.Bounds()
+		rects[i] = image.Rectangle{Min: origin, Max: origin.Add(b.Size())}
+		draw.Draw(sheet, rects[i], f.Image, b.Min, draw.Src)
+	}
+	return sheet, rects, nil
+}
+
+// writePack packs the images in dir into a sprite map written to filename, together with the
+// requested exports describing it with the pivot anchor. If data is set, the texturepacker export
+// is written to it.
+func writePack(dir string, filename string, columns int, padding int, size image.Point, pivot string, data string, exports exportList) error {
+	frames, readErr := readFrameDir(dir)
+	if readErr != nil {
+		return readErr
+	}
+	if len(frames) == 0 {
+		return fmt.Errorf("no images found in %s", dir)
+	}
+	sheet, rects, packErr := pack(frames, columns, padding, size)
+	if packErr != nil {
+		return packErr
+	}
+	if !saveImage(sheet, filename) {
+		return fmt.Errorf("cannot write %s", filename)
+	}
+	e := &sheetExport{Base: strings.TrimSuffix(filename, filepath.Ext(filename)), Image: filename, Data: data, Size: sheet.Bounds().Size(), Pivot: pivot}
+	for i, f := range frames {
+		e.Regions = append(e.Regions, sheetRegion{Name: strings.TrimSuffix(f.Name, filepath.Ext(f.Name)), Rect: rects[i]})
+	}
+	return exports.writeExports(e)
+}
+
+// parseSize parses a size given as <width>x<height>.
+func parseSize(value string) (image.Point, error) {
+	widthText, heightText, ok := strings.Cut(value, "x")
+	if !ok {
+		return image.Point{}, fmt.Errorf("expected <width>x<height>")
+	}
+	width, widthErr := strconv.Atoi(widthText)
+	if widthErr != nil {
+		return image.Point{}, widthErr
+	}
+	height, heightErr := strconv.Atoi(heightText)
+	if heightErr != nil {
+		return image.Point{}, heightErr
+	}
+	if width <= 0 || height <= 0 {
+		return image.Point{}, fmt.Errorf("width and height must be positive")
+	}
+	return image.Pt(width, height), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// paletteWriters write the colors as palette files of the editors, by the extension of -palette.
+var paletteWriters = map[string]func(colors []color.NRGBA, name string) string{
+	".gpl": gimpPalette,
+	".pal": jascPalette,
+}
+
+// paletteExtensions returns the supported extensions of -palette.
+func paletteExtensions() string {
+	var extensions []string
+	for extension := range paletteWriters {
+		extensions = append(extensions, extension)
+	}
+	sort.Strings(extensions)
+	return strings.Join(extensions, ", ")
+}
+
+// uniqueColors returns the colors of the image that are not fully transparent, without their
+// alpha, in the order they first appear row by row.
+func uniqueColors(img image.Image) []color.NRGBA {
+	var colors []color.NRGBA
+	seen := make(map[color.NRGBA]bool)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			c.A = 255
+			if !seen[c] {
+				seen[c] = true
+				colors = append(colors, c)
+			}
+		}
+	}
+	return colors
+}
+
+// gimpPalette returns the colors as a GIMP .gpl palette of the given name.
+func gimpPalette(colors []color.NRGBA, name string) string {
+	var gpl strings.Builder
+	fmt.Fprintf(&gpl, "GIMP Palette\nName: %s\nColumns: 16\n#\n", name)
+	for _, c := range colors {
+		fmt.Fprintf(&gpl, "%3d %3d %3d\t#%02x%02x%02x\n", c.R, c.G, c.B, c.R, c.G, c.B)
+	}
+	return gpl.String()
+}
+
+// jascPalette returns the colors as a JASC .pal palette, as read by Paint Shop Pro and Aseprite.
+func jascPalette(colors []color.NRGBA, _ string) string {
+	var pal strings.Builder
+	fmt.Fprintf(&pal, "JASC-PAL\r\n0100\r\n%d\r\n", len(colors))
+	for _, c := range colors {
+		fmt.Fprintf(&pal, "%d %d %d\r\n", c.R, c.G, c.B)
+	}
+	return pal.String()
+}
+
+// writePalette writes the unique colors of the sprite map of a to the -palette file, in the
+// format of its extension.
+func writePalette(a *args) error {
+	file, openErr := os.Open(a.Filename)
+	if openErr != nil {
+		return openErr
+	}
+	img, _, decodeErr := decodeImage(file, a.Filename)
+	file.Close()
+	if decodeErr != nil {
+		return decodeErr
+	}
+	if spriteMap, ok := img.(SpriteMap); ok {
+		img = a.keySheet(spriteMap)
+	}
+	name := strings.TrimSuffix(path.Base(a.Filename), path.Ext(a.Filename))
+	palette := paletteWriters[strings.ToLower(path.Ext(a.Palette))](uniqueColors(img), name)
+	return writeFile(a.Palette, []byte(palette), 0644)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hschendel/spritemap-explode/spritemapexplode"
+)
+
+// loadImage decodes the image file filename.
+func loadImage(filename string) (image.Image, error) {
+	file, openErr := os.Open(filename)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+	img, _, decodeErr := decodeImage(file, filename)
+	return img, decodeErr
+}
+
+// image8Bit returns the image with 8 bits per channel, converting it if it has 16.
+func image8Bit(img image.Image) image.Image {
+	if !spritemapexplode.Is16Bit(img) {
+		return img
+	}
+	converted := image.NewNRGBA(img.Bounds())
+	draw.Draw(converted, converted.Bounds(), img, img.Bounds().Min, draw.Src)
+	return converted
+}
+
+// newComposite returns a transparent image of the bounds r to draw img together with other images
+// onto, with 16 bits per channel if img has them.
+func newComposite(img image.Image, r image.Rectangle) draw.Image {
+	if spritemapexplode.Is16Bit(img) {
+		return image.NewNRGBA64(r)
+	}
+	return image.NewNRGBA(r)
+}
+
+// imageFlatten composites the image over the opaque color c, removing its transparency.
+func imageFlatten(img image.Image, c color.NRGBA) image.Image {
+	c.A = 255
+	flat := newComposite(img, img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return flat
+}
+
+// keyMatches returns if the channels of c all differ by at most tolerance from the key.
+func keyMatches(c color.NRGBA, key color.NRGBA, tolerance uint8) bool {
+	near := func(a uint8, b uint8) bool {
+		return max(a, b)-min(a, b) <= tolerance
+	}
+	return near(c.R, key.R) && near(c.G, key.G) && near(c.B, key.B)
+}
+
+// imageColorKey returns a copy of the image with the pixels of the key color made transparent.
+// Pixels whose channels all differ by at most tolerance from the key match too.
+func imageColorKey(img image.Image, key color.NRGBA, tolerance uint8) *image.NRGBA {
+	keyed := image.NewNRGBA(img.Bounds())
+	draw.Draw(keyed, keyed.Bounds(), img, img.Bounds().Min, draw.Src)
+	b := keyed.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if keyMatches(keyed.NRGBAAt(x, y), key, tolerance) {
+				keyed.SetNRGBA(x, y, color.NRGBA{})
+			}
+		}
+	}
+	return keyed
+}
+
+// palettedColorKey returns a copy of the paletted image with the palette entries of the key color
+// made transparent, so that the frames keep their palette and indices.
+func palettedColorKey(img *image.Paletted, key color.NRGBA, tolerance uint8) *image.Paletted {
+	keyed := &image.Paletted{Pix: slices.Clone(img.Pix), Stride: img.Stride, Rect: img.Rect, Palette: slices.Clone(img.Palette)}
+	for i, c := range keyed.Palette {
+		if keyMatches(color.NRGBAModel.Convert(c).(color.NRGBA), key, tolerance) {
+			keyed.Palette[i] = color.NRGBA{}
+		}
+	}
+	return keyed
+}
+
+// keySheet makes the -colorkey color of the sprite map transparent, so that it is treated like
+// transparency when looking for empty frames and in all written frames. Paletted sprite maps stay
+// paletted.
+func (a *args) keySheet(img SpriteMap) SpriteMap {
+	if !a.ColorKey.Valid {
+		return img
+	}
+	if paletted, ok := img.(*image.Paletted); ok {
+		return palettedColorKey(paletted, a.ColorKey.Color, a.ColorKey.Tolerance)
+	}
+	return imageColorKey(img, a.ColorKey.Color, a.ColorKey.Tolerance)
+}
+
+// imageUnderlay draws the image over the underlay, which is repeated to cover the whole image.
+func imageUnderlay(img image.Image, underlay image.Image) image.Image {
+	composite := newComposite(img, img.Bounds())
+	b, u := composite.Bounds(), underlay.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y += u.Dy() {
+		for x := b.Min.X; x < b.Max.X; x += u.Dx() {
+			draw.Draw(composite, image.Rect(x, y, x+u.Dx(), y+u.Dy()), underlay, u.Min, draw.Src)
+		}
+	}
+	draw.Draw(composite, composite.Bounds(), img, img.Bounds().Min, draw.Over)
+	return composite
+}
+
+// imageWatermark draws the watermark centered over the image.
+func imageWatermark(img image.Image, watermark image.Image) image.Image {
+	composite := newComposite(img, img.Bounds())
+	draw.Draw(composite, composite.Bounds(), img, img.Bounds().Min, draw.Src)
+	b, w := composite.Bounds(), watermark.Bounds()
+	offset := b.Min.Add(b.Size().Sub(w.Size()).Div(2))
+	draw.Draw(composite, image.Rectangle{Min: offset, Max: offset.Add(w.Size())}, watermark, w.Min, draw.Over)
+	return composite
+}
+
+// imageDilate fills the color of fully transparent pixels with the average color of their
+// neighbors, growing outwards from the visible pixels until all are filled. The alpha values are
+// kept, so the image looks the same, but scaling or mipmapping it does not blend in dark halos.
+func imageDilate(img image.Image) image.Image {
+	dilated := image.NewNRGBA(img.Bounds())
+	draw.Draw(dilated, dilated.Bounds(), img, img.Bounds().Min, draw.Src)
+	b := dilated.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// filled marks the pixels having a color, queued those that are about to get one, so that every
+	// pixel enters the frontier only once
+	filled := make([]bool, w*h)
+	queued := make([]bool, w*h)
+	for y := range h {
+		for x := range w {
+			filled[y*w+x] = dilated.NRGBAAt(b.Min.X+x, b.Min.Y+y).A != 0
+		}
+	}
+	neighbors := func(p image.Point, visit func(n image.Point, i int)) {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				n := image.Pt(p.X+dx, p.Y+dy)
+				if (dx != 0 || dy != 0) && n.X >= 0 && n.Y >= 0 && n.X < w && n.Y < h {
+					visit(n, n.Y*w+n.X)
+				}
+			}
+		}
+	}
+	var frontier []image.Point
+	enqueue := func(n image.Point, i int) {
+		if !filled[i] && !queued[i] {
+			queued[i] = true
+			frontier = append(frontier, n)
+		}
+	}
+	for y := range h {
+		for x := range w {
+			if filled[y*w+x] {
+				neighbors(image.Pt(x, y), enqueue)
+			}
+		}
+	}
+
+	for len(frontier) > 0 {
+		layer := frontier
+		colors := make([]color.NRGBA, len(layer))
+		for i, p := range layer {
+			var r, g, bl, n int
+			neighbors(p, func(q image.Point, j int) {
+				if filled[j] {
+					c := dilated.NRGBAAt(b.Min.X+q.X, b.Min.Y+q.Y)
+					r, g, bl, n = r+int(c.R), g+int(c.G), bl+int(c.B), n+1
+				}
+			})
+			colors[i] = color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n)}
+		}
+		frontier = nil
+		for i, p := range layer {
+			dilated.SetNRGBA(b.Min.X+p.X, b.Min.Y+p.Y, colors[i])
+			filled[p.Y*w+p.X] = true
+		}
+		for _, p := range layer {
+			neighbors(p, enqueue)
+		}
+	}
+	return dilated
+}
+
+// imageScale enlarges the image by an integer factor, repeating every pixel factor times in both
+// directions.
+func imageScale(img image.Image, factor int) image.Image {
+	b := img.Bounds()
+	scaled := spritemapexplode.NewImage(img, image.Rectangle{Min: b.Min, Max: b.Min.Add(b.Size().Mul(factor))})
+	for y := 0; y < b.Dy()*factor; y++ {
+		for x := 0; x < b.Dx()*factor; x++ {
+			scaled.Set(b.Min.X+x, b.Min.Y+y, img.At(b.Min.X+x/factor, b.Min.Y+y/factor))
+		}
+	}
+	return scaled
+}
+
+// imageExtrude grows the image by n pixels on every side, repeating its border pixels outwards.
+func imageExtrude(img image.Image, n int) image.Image {
+	b := img.Bounds()
+	extruded := spritemapexplode.NewImage(img, image.Rectangle{Max: b.Size().Add(image.Pt(2*n, 2*n))})
+	for y := 0; y < b.Dy()+2*n; y++ {
+		for x := 0; x < b.Dx()+2*n; x++ {
+			sx := min(max(b.Min.X+x-n, b.Min.X), b.Max.X-1)
+			sy := min(max(b.Min.Y+y-n, b.Min.Y), b.Max.Y-1)
+			extruded.Set(x, y, img.At(sx, sy))
+		}
+	}
+	return extruded
+}
+
+// anchors are the supported values of -pot-anchor, mapped to the position of the image within the
+// padded area in halves of the free space.
+var anchors = map[string]image.Point{
+	"top-left": {0, 0}, "top": {1, 0}, "top-right": {2, 0},
+	"left": {0, 1}, "center": {1, 1}, "right": {2, 1},
+	"bottom-left": {0, 2}, "bottom": {1, 2}, "bottom-right": {2, 2},
+}
+
+// anchorNames returns the names of the supported anchors.
+func anchorNames() string {
+	names := make([]string, 0, len(anchors))
+	for name := range anchors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// nextPowerOfTwo returns the smallest power of two that is at least n.
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+// imagePad places the image at the anchor within a transparent image of the given size.
+func imagePad(img image.Image, size image.Point, anchor string) image.Image {
+	b := img.Bounds()
+	padded := spritemapexplode.NewImage(img, image.Rectangle{Max: size})
+	offset := size.Sub(b.Size())
+	offset = image.Pt(offset.X*anchors[anchor].X/2, offset.Y*anchors[anchor].Y/2)
+	draw.Draw(padded, b.Sub(b.Min).Add(offset), img, b.Min, draw.Src)
+	return padded
+}
+
+// postProcess applies the output options of a to a frame right before it is written, after it
+// has been scaled and trimmed.
+func (a *args) postProcess(img image.Image) image.Image {
+	if a.Extrude > 0 {
+		img = imageExtrude(img, int(a.Extrude))
+	}
+	if a.POT {
+		size := img.Bounds().Size()
+		img = imagePad(img, image.Pt(nextPowerOfTwo(size.X), nextPowerOfTwo(size.Y)), a.POTAnchor)
+	}
+	if a.underlay != nil {
+		img = imageUnderlay(img, a.underlay)
+	}
+	if a.watermark != nil {
+		img = imageWatermark(img, a.watermark)
+	}
+	if a.Dilate {
+		img = imageDilate(img)
+	}
+	if a.Flatten.Valid {
+		img = imageFlatten(img, a.Flatten.Color)
+	}
+	return img
+}
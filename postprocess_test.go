@@ -0,0 +1,29 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestKeySheetPaletted(t *testing.T) {
+	magenta, red := color.NRGBA{R: 255, B: 255, A: 255}, color.NRGBA{R: 255, A: 255}
+	sheet := image.NewPaletted(image.Rect(0, 0, 2, 1), color.Palette{magenta, red})
+	sheet.SetColorIndex(1, 0, 1)
+	a := &args{}
+	a.ColorKey.Valid, a.ColorKey.Color = true, magenta
+
+	keyed, ok := a.keySheet(sheet).(*image.Paletted)
+	if !ok {
+		t.Fatal("the keyed sprite map is not paletted")
+	}
+	if keyed.ColorIndexAt(0, 0) != 0 || keyed.ColorIndexAt(1, 0) != 1 {
+		t.Errorf("indices changed to %v", keyed.Pix)
+	}
+	if keyed.Palette[0] != (color.NRGBA{}) || keyed.Palette[1] != red {
+		t.Errorf("palette is %v, expected the key transparent", keyed.Palette)
+	}
+	if sheet.Palette[0] != magenta {
+		t.Error("the palette of the original sprite map changed")
+	}
+}
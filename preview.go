@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Source}}</title>
+<style>
+body { font-family: sans-serif; background: #303030; color: #e0e0e0; }
+.frames { display: flex; flex-wrap: wrap; gap: 1em; }
+.frame { display: flex; flex-direction: column; align-items: center; font-size: small; }
+.checker { background: repeating-conic-gradient(#808080 0% 25%, #a0a0a0 0% 50%) 50% / 16px 16px; }
+.frame img, .animation { image-rendering: pixelated; }
+.animation { background-repeat: no-repeat; animation-iteration-count: infinite; animation-timing-function: step-end; }
+{{range $i, $a := .Animations}}
+@keyframes animation{{$i}} {
+{{- range $a.Keyframes}}
+	{{.Percent}}% { background-image: url("{{.Src}}"); }
+{{- end}}
+}
+{{- end}}
+</style>
+</head>
+<body>
+<h1>{{.Source}}</h1>
+<h2>Animations</h2>
+<div class="frames">
+{{- range $i, $a := .Animations}}
+<div class="frame">
+<div class="checker"><div class="animation" style="width: {{$a.Width}}px; height: {{$a.Height}}px; animation-name: animation{{$i}}; animation-duration: {{$a.Duration}}ms;"></div></div>
+<span>{{$a.Name}}</span>
+</div>
+{{- end}}
+</div>
+<h2>Frames</h2>
+<div class="frames">
+{{- range .Frames}}
+<div class="frame">
+<img class="checker" src="{{.Src}}" width="{{.Size.X}}" height="{{.Size.Y}}" alt="{{.Name}}">
+<span>{{.Name}}</span>
+<span>row {{.Row}}, column {{.Column}}, {{.Size.X}}x{{.Size.Y}}</span>
+<span>from {{.Rect.Min.X}},{{.Rect.Min.Y}} {{.Rect.Dx}}x{{.Rect.Dy}}</span>
+</div>
+{{- end}}
+</div>
+{{- if .LiveReload}}
+<script>
+setInterval(function() {
+	fetch("/version").then(function(r) { return r.text(); }).then(function(v) {
+		if (v !== "{{.Version}}") { location.reload(); }
+	});
+}, 500);
+</script>
+{{- end}}
+</body>
+</html>
+`))
+
+type previewFrame struct {
+	frameFile
+	Src  string
+	Name string
+}
+
+type previewKeyframe struct {
+	Percent string
+	Src     string
+}
+
+type previewAnimation struct {
+	Name          string
+	Width, Height int
+	Duration      int
+	Keyframes     []previewKeyframe
+}
+
+// writeHTMLPreview writes an HTML page showing all frames with their names, cells, sizes and
+// source rectangles, and every row played back as a CSS animation.
+func writeHTMLPreview(filename string, source string, files []frameFile) error {
+	file, createErr := createFile(filename)
+	if createErr != nil {
+		return createErr
+	}
+	renderErr := renderHTMLPreview(file, filepath.Dir(filename), source, files, 0)
+	closeErr := file.Close()
+	if renderErr != nil {
+		return renderErr
+	}
+	return closeErr
+}
+
+// renderHTMLPreview renders the HTML preview page for a page located in dir, with every frame of
+// the animations shown for its duration. If version is not 0, the page polls /version and reloads
+// once it changes.
+func renderHTMLPreview(w io.Writer, dir string, source string, files []frameFile, version int) error {
+	data := struct {
+		Source     string
+		Frames     []previewFrame
+		Animations []*previewAnimation
+		LiveReload bool
+		Version    string
+	}{Source: filepath.Base(source), LiveReload: version != 0, Version: strconv.Itoa(version)}
+
+	srcs := make(map[string]string, len(files))
+	for _, f := range files {
+		src, relErr := filepath.Rel(dir, f.Filename)
+		if relErr != nil {
+			return relErr
+		}
+		srcs[f.Filename] = filepath.ToSlash(src)
+		data.Frames = append(data.Frames, previewFrame{frameFile: f, Src: srcs[f.Filename], Name: filepath.Base(f.Filename)})
+	}
+
+	for _, r := range rowAnimations(files) {
+		size := r.Size()
+		n := len(r.Frames)
+		name := r.Name()
+		if r.Animation == "" {
+			name = "row " + name
+		}
+		a := &previewAnimation{Name: name, Width: size.X, Height: size.Y, Duration: r.Duration()}
+		frameDuration := func(f frameFile) int { return f.Duration }
+		if a.Duration <= 0 {
+			// frames without durations are shown for 100 ms each, like at the default -fps
+			a.Duration = n * 100
+			frameDuration = func(frameFile) int { return 100 }
+		}
+		elapsed := 0
+		for _, f := range r.Frames {
+			percent := strconv.FormatFloat(float64(elapsed)*100/float64(a.Duration), 'f', 2, 64)
+			a.Keyframes = append(a.Keyframes, previewKeyframe{Percent: percent, Src: srcs[f.Filename]})
+			elapsed += frameDuration(f)
+		}
+		a.Keyframes = append(a.Keyframes, previewKeyframe{Percent: "100", Src: srcs[r.Frames[n-1].Filename]})
+		data.Animations = append(data.Animations, a)
+	}
+
+	return previewTemplate.Execute(w, data)
+}
+
+// servePreview explodes the sprite map and serves the HTML preview of the frames. The source
+// file is polled for changes, upon which it is exploded again and open pages reload.
+func servePreview(a *args) error {
+	var mu sync.Mutex
+	files, _ := process(a)
+	version := 1
+	lastMod := fileModTime(a.Filename)
+
+	go func() {
+		for range time.Tick(500 * time.Millisecond) {
+			modTime := fileModTime(a.Filename)
+			if modTime.Equal(lastMod) {
+				continue
+			}
+			lastMod = modTime
+			newFiles, _ := process(a)
+			mu.Lock()
+			files = newFiles
+			version++
+			mu.Unlock()
+		}
+	}()
+
+	dir := filepath.Dir(a.Prefix)
+	frameServer := http.FileServer(http.Dir(dir))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if r.URL.Path != "/" {
+			frameServer.ServeHTTP(w, r)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if renderErr := renderHTMLPreview(w, dir, a.Filename, files, version); renderErr != nil {
+			http.Error(w, renderErr.Error(), http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(w, version)
+	})
+
+	fmt.Fprintln(os.Stderr, "Serving preview on http://"+a.Preview+"/")
+	return http.ListenAndServe(a.Preview, mux)
+}
+
+// fileModTime returns the modification time of filename, or the zero time if it cannot be read.
+func fileModTime(filename string) time.Time {
+	info, statErr := os.Stat(filename)
+	if statErr != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
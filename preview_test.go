@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHTMLPreviewWithoutDurations(t *testing.T) {
+	files := []frameFile{{Filename: "sheet-0-0.png"}, {Filename: "sheet-0-1.png", Column: 1}}
+	var page bytes.Buffer
+	if renderErr := renderHTMLPreview(&page, ".", "sheet.png", files, 0); renderErr != nil {
+		t.Fatal(renderErr)
+	}
+	if strings.Contains(page.String(), "NaN") {
+		t.Error("the keyframes contain NaN")
+	}
+	for _, expected := range []string{"animation-duration: 200ms", "50.00%"} {
+		if !strings.Contains(page.String(), expected) {
+			t.Errorf("the page does not contain %s", expected)
+		}
+	}
+}
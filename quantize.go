@@ -0,0 +1,127 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// colorCount is a color of a sprite map together with the number of its pixels.
+type colorCount struct {
+	Color color.NRGBA
+	Count int
+}
+
+// colorBox is a group of colors of the median cut, which becomes one palette entry.
+type colorBox []colorCount
+
+// channel returns the channel i, in the order red, green, blue and alpha, of the color.
+func channel(c color.NRGBA, i int) uint8 {
+	return [4]uint8{c.R, c.G, c.B, c.A}[i]
+}
+
+// widestChannel returns the channel whose values differ the most within the box, and how much.
+func (b colorBox) widestChannel() (int, int) {
+	widest, width := 0, -1
+	for i := range 4 {
+		lo, hi := uint8(255), uint8(0)
+		for _, c := range b {
+			lo, hi = min(lo, channel(c.Color, i)), max(hi, channel(c.Color, i))
+		}
+		if int(hi)-int(lo) > width {
+			widest, width = i, int(hi)-int(lo)
+		}
+	}
+	return widest, width
+}
+
+// average returns the mean color of the box, weighted by the pixel counts.
+func (b colorBox) average() color.NRGBA {
+	var sum [4]int
+	total := 0
+	for _, c := range b {
+		for i := range sum {
+			sum[i] += int(channel(c.Color, i)) * c.Count
+		}
+		total += c.Count
+	}
+	return color.NRGBA{R: uint8(sum[0] / total), G: uint8(sum[1] / total), B: uint8(sum[2] / total), A: uint8(sum[3] / total)}
+}
+
+// split divides the box at the median pixel of its widest channel.
+func (b colorBox) split() (colorBox, colorBox) {
+	i, _ := b.widestChannel()
+	sort.SliceStable(b, func(x, y int) bool { return channel(b[x].Color, i) < channel(b[y].Color, i) })
+	total := 0
+	for _, c := range b {
+		total += c.Count
+	}
+	half, at := 0, 1
+	for at < len(b)-1 {
+		if half += b[at-1].Count; half*2 >= total {
+			break
+		}
+		at++
+	}
+	return b[:at], b[at:]
+}
+
+// sharedPalette computes a palette of at most size colors for the pixels of all cells by median
+// cut. Index 0 is transparent if any pixel is.
+func sharedPalette(img SpriteMap, cells []cell, size int) color.Palette {
+	counts := make(map[color.NRGBA]int)
+	for _, c := range cells {
+		for y := c.Rect.Min.Y; y < c.Rect.Max.Y; y++ {
+			for x := c.Rect.Min.X; x < c.Rect.Max.X; x++ {
+				counts[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)]++
+			}
+		}
+	}
+	var palette color.Palette
+	box := colorBox{}
+	for c, n := range counts {
+		if c.A == 0 {
+			if len(palette) == 0 {
+				palette = append(palette, color.NRGBA{})
+			}
+			continue
+		}
+		box = append(box, colorCount{Color: c, Count: n})
+	}
+	if len(box) == 0 {
+		return append(palette, color.NRGBA{A: 255})
+	}
+	// map order is random, sorting keeps the palette the same from one run to the next
+	sort.Slice(box, func(i, j int) bool {
+		a, b := box[i].Color, box[j].Color
+		return uint32(a.R)<<24|uint32(a.G)<<16|uint32(a.B)<<8|uint32(a.A) < uint32(b.R)<<24|uint32(b.G)<<16|uint32(b.B)<<8|uint32(b.A)
+	})
+
+	boxes := []colorBox{box}
+	for len(palette)+len(boxes) < size {
+		widest, width := -1, 0
+		for i, b := range boxes {
+			if _, w := b.widestChannel(); len(b) > 1 && w > width {
+				widest, width = i, w
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		first, second := boxes[widest].split()
+		boxes[widest] = first
+		boxes = append(boxes, second)
+	}
+	for _, b := range boxes {
+		palette = append(palette, b.average())
+	}
+	return palette
+}
+
+// imagePaletted converts the image to the palette, mapping every pixel to the nearest color.
+func imagePaletted(img image.Image, palette color.Palette) *image.Paletted {
+	paletted := image.NewPaletted(img.Bounds(), palette)
+	draw.Draw(paletted, paletted.Bounds(), img, img.Bounds().Min, draw.Src)
+	return paletted
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rectList collects the cells given by repeated -rect name=x,y,w,h flags.
+type rectList []cell
+
+func (l *rectList) String() string {
+	var specs []string
+	for _, c := range *l {
+		specs = append(specs, fmt.Sprintf("%s=%d,%d,%d,%d", c.Name, c.Rect.Min.X, c.Rect.Min.Y, c.Rect.Dx(), c.Rect.Dy()))
+	}
+	return strings.Join(specs, " ")
+}
+
+func (l *rectList) Set(value string) error {
+	name, spec, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=x,y,w,h")
+	}
+	for _, c := range *l {
+		if c.Name == name {
+			return fmt.Errorf("duplicate rectangle name %s", name)
+		}
+	}
+	fields := strings.Split(spec, ",")
+	if len(fields) != 4 {
+		return fmt.Errorf("expected name=x,y,w,h")
+	}
+	var values [4]int
+	for i, field := range fields {
+		v, parseErr := strconv.Atoi(strings.TrimSpace(field))
+		if parseErr != nil {
+			return parseErr
+		}
+		values[i] = v
+	}
+	if values[2] <= 0 || values[3] <= 0 {
+		return fmt.Errorf("width and height of %s must be positive", name)
+	}
+	x, y := values[0], values[1]
+	*l = append(*l, cell{Rect: image.Rect(x, y, x+values[2], y+values[3]), Column: len(*l), Name: name})
+	return nil
+}
+
+// loadRects reads the cells of a file with one rectangle per line as x,y,w,h or x,y,w,h,name.
+// Unnamed rectangles are numbered in the order of the file as the columns of row 0. Lines starting
+// with # are comments.
+func loadRects(filename string) ([]cell, error) {
+	file, openErr := os.Open(filename)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var cells []cell
+	names := make(map[string]bool)
+	for {
+		record, readErr := r.Read()
+		if readErr == io.EOF {
+			return cells, nil
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) != 4 && len(record) != 5 {
+			return nil, fmt.Errorf("line %d: expected x,y,w,h or x,y,w,h,name", line)
+		}
+		var values [4]int
+		for i := range values {
+			v, atoiErr := strconv.Atoi(strings.TrimSpace(record[i]))
+			if atoiErr != nil {
+				return nil, fmt.Errorf("line %d: %q is not a number", line, record[i])
+			}
+			values[i] = v
+		}
+		if values[2] <= 0 || values[3] <= 0 {
+			return nil, fmt.Errorf("line %d: width and height must be positive", line)
+		}
+		c := cell{Rect: image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3]), Column: len(cells)}
+		if len(record) == 5 {
+			c.Name = strings.TrimSpace(record[4])
+			if names[c.Name] {
+				return nil, fmt.Errorf("line %d: duplicate rectangle name %s", line, c.Name)
+			}
+			names[c.Name] = true
+		}
+		cells = append(cells, c)
+	}
+}
+
+// sizeList is a comma separated list of positive sizes, like the heights given by -row-heights.
+type sizeList []int
+
+func (l *sizeList) String() string {
+	var sizes []string
+	for _, size := range *l {
+		sizes = append(sizes, strconv.Itoa(size))
+	}
+	return strings.Join(sizes, ",")
+}
+
+func (l *sizeList) Set(value string) error {
+	var sizes sizeList
+	for _, field := range strings.Split(value, ",") {
+		size, parseErr := strconv.Atoi(strings.TrimSpace(field))
+		if parseErr != nil {
+			return parseErr
+		}
+		if size <= 0 {
+			return fmt.Errorf("sizes must be positive")
+		}
+		sizes = append(sizes, size)
+	}
+	*l = sizes
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// resolution is a scale of -resolutions, named like 2x or 0.5x.
+type resolution struct {
+	Name   string
+	Factor float64
+}
+
+// resolutionList is the comma separated list of -resolutions.
+type resolutionList []resolution
+
+func (l *resolutionList) String() string {
+	var names []string
+	for _, r := range *l {
+		names = append(names, r.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (l *resolutionList) Set(value string) error {
+	for _, field := range strings.Split(value, ",") {
+		name := strings.TrimSuffix(strings.TrimSpace(field), "x")
+		factor, parseErr := strconv.ParseFloat(name, 64)
+		if parseErr != nil || factor <= 0 {
+			return fmt.Errorf("expected scales like 2x or 0.5x")
+		}
+		*l = append(*l, resolution{Name: name + "x", Factor: factor})
+	}
+	return nil
+}
+
+// imageResize scales the image by factor. Integer factors enlarge it like -scale does, with the
+// -scale-filter if it supports the factor, all others are resampled smoothly.
+func (a *args) imageResize(img image.Image, factor float64) image.Image {
+	if n := int(factor); factor == float64(n) {
+		if f, ok := scaleFilters[a.ScaleFilter]; ok {
+			if _, stepsErr := f.scaleSteps(n); stepsErr == nil {
+				return imageUpscale(img, n, a.ScaleFilter)
+			}
+		}
+		return imageScale(img, n)
+	}
+	b := img.Bounds()
+	size := image.Pt(max(int(float64(b.Dx())*factor+0.5), 1), max(int(float64(b.Dy())*factor+0.5), 1))
+	resized := image.NewNRGBA(image.Rectangle{Max: size})
+	xdraw.CatmullRom.Scale(resized, resized.Bounds(), img, b, xdraw.Src, nil)
+	return resized
+}
+
+// saveResolutions writes the frame at every scale of -resolutions other than 1x into a
+// subdirectory of the frame's directory named after the scale, e.g. 2x/hero-0-0.png.
+func (a *args) saveResolutions(img image.Image, filename string) error {
+	for _, r := range a.Resolutions {
+		if r.Factor == 1 {
+			continue
+		}
+		dir := path.Join(path.Dir(filename), r.Name)
+		if mkdirErr := os.MkdirAll(dir, 0755); mkdirErr != nil {
+			return mkdirErr
+		}
+		if resized := path.Join(dir, path.Base(filename)); !saveImage(a.imageResize(img, r.Factor), resized) {
+			return fmt.Errorf("cannot write %s", resized)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// frameInfo is what a frame script gets to see about a frame.
+type frameInfo struct {
+	Row    int
+	Column int
+	Empty  bool
+	Bounds image.Rectangle
+}
+
+// frameDecision is what a frame script decided to do with a frame.
+type frameDecision struct {
+	Skip      bool
+	Name      string
+	Transform string
+}
+
+// frameScript is a Starlark script defining a function frame(f) that is called for every frame.
+// The function may return None to keep the default behaviour, a bool telling whether to keep
+// the frame, a string to rename the frame, or a dict with the optional keys "skip", "name"
+// and "transform".
+type frameScript struct {
+	thread *starlark.Thread
+	fn     starlark.Callable
+}
+
+func loadFrameScript(filename string) (*frameScript, error) {
+	thread := &starlark.Thread{Name: filename}
+	globals, execErr := starlark.ExecFile(thread, filename, nil, nil)
+	if execErr != nil {
+		return nil, execErr
+	}
+	fn, ok := globals["frame"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("script does not define a function frame(f)")
+	}
+	return &frameScript{thread: thread, fn: fn}, nil
+}
+
+// Decide calls frame(f) for the frame. If it returns None, the decision is the default one given.
+func (s *frameScript) Decide(info frameInfo, decision frameDecision) (frameDecision, error) {
+	f := starlarkstruct.FromStringDict(starlark.String("frame"), starlark.StringDict{
+		"row":    starlark.MakeInt(info.Row),
+		"column": starlark.MakeInt(info.Column),
+		"empty":  starlark.Bool(info.Empty),
+		"bounds": starlark.Tuple{
+			starlark.MakeInt(info.Bounds.Min.X),
+			starlark.MakeInt(info.Bounds.Min.Y),
+			starlark.MakeInt(info.Bounds.Max.X),
+			starlark.MakeInt(info.Bounds.Max.Y),
+		},
+	})
+	result, callErr := starlark.Call(s.thread, s.fn, starlark.Tuple{f}, nil)
+	if callErr != nil {
+		return decision, callErr
+	}
+
+	switch v := result.(type) {
+	case starlark.NoneType:
+	case starlark.Bool:
+		decision.Skip = !bool(v)
+	case starlark.String:
+		decision.Name = string(v)
+		decision.Skip = false
+	case *starlark.Dict:
+		decision.Skip = false
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return decision, fmt.Errorf("frame() returned a dict with non-string key %s", item[0])
+			}
+			switch key {
+			case "skip":
+				decision.Skip = bool(item[1].Truth())
+			case "name":
+				if decision.Name, ok = starlark.AsString(item[1]); !ok {
+					return decision, fmt.Errorf("frame() returned a non-string name %s", item[1])
+				}
+			case "transform":
+				if decision.Transform, ok = starlark.AsString(item[1]); !ok {
+					return decision, fmt.Errorf("frame() returned a non-string transform %s", item[1])
+				}
+				if _, known := imageTransforms[decision.Transform]; !known {
+					return decision, fmt.Errorf("frame() returned unknown transform %q", decision.Transform)
+				}
+			default:
+				return decision, fmt.Errorf("frame() returned a dict with unknown key %q", key)
+			}
+		}
+	default:
+		return decision, fmt.Errorf("frame() returned unsupported value of type %s", result.Type())
+	}
+	return decision, nil
+}
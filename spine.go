@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// spineInts parses the comma separated integers of a Spine atlas entry.
+func spineInts(value string, count int) ([]int, error) {
+	fields := strings.Split(value, ",")
+	if len(fields) != count {
+		return nil, fmt.Errorf("expected %d values in %q", count, value)
+	}
+	ints := make([]int, count)
+	for i, field := range fields {
+		v, atoiErr := strconv.Atoi(strings.TrimSpace(field))
+		if atoiErr != nil {
+			return nil, atoiErr
+		}
+		ints[i] = v
+	}
+	return ints, nil
+}
+
+// spineRegion converts the entries of a region of a Spine atlas, in the format of Spine 4 with
+// bounds and offsets or the older one with xy, size, orig and offset, into an atlas frame. The
+// offsets of Spine count y from the bottom.
+func spineRegion(name string, entries map[string]string) (atlasFrame, error) {
+	f := atlasFrame{Filename: name, Counterclockwise: true}
+	var bounds, offsets []int
+	var boundsErr, offsetsErr error
+	if value, ok := entries["bounds"]; ok {
+		bounds, boundsErr = spineInts(value, 4)
+	} else {
+		var xy, size []int
+		if xy, boundsErr = spineInts(entries["xy"], 2); boundsErr == nil {
+			size, boundsErr = spineInts(entries["size"], 2)
+			bounds = append(xy, size...)
+		}
+	}
+	if boundsErr != nil {
+		return f, fmt.Errorf("region %s: %v", name, boundsErr)
+	}
+	if value, ok := entries["offsets"]; ok {
+		offsets, offsetsErr = spineInts(value, 4)
+	} else if value, ok := entries["offset"]; ok {
+		var offset, orig []int
+		if offset, offsetsErr = spineInts(value, 2); offsetsErr == nil {
+			orig, offsetsErr = spineInts(entries["orig"], 2)
+			offsets = append(offset, orig...)
+		}
+	}
+	if offsetsErr != nil {
+		return f, fmt.Errorf("region %s: %v", name, offsetsErr)
+	}
+
+	f.Frame = atlasRect{X: bounds[0], Y: bounds[1], W: bounds[2], H: bounds[3]}
+	switch rotate := entries["rotate"]; rotate {
+	case "", "false", "0":
+	case "true", "90":
+		f.Rotated = true
+	default:
+		return f, fmt.Errorf("region %s: unsupported rotation %s", name, rotate)
+	}
+	if offsets != nil && (offsets[2] != bounds[2] || offsets[3] != bounds[3]) {
+		f.Trimmed = true
+		f.SpriteSourceSize = atlasRect{X: offsets[0], Y: offsets[3] - offsets[1] - bounds[3], W: bounds[2], H: bounds[3]}
+		f.SourceSize.W, f.SourceSize.H = offsets[2], offsets[3]
+	}
+	if index, ok := entries["index"]; ok && index != "-1" {
+		f.Filename += "_" + index
+	}
+	return f, nil
+}
+
+// loadSpineAtlas reads the regions of the page imageFilename from a Spine atlas. If the atlas has
+// only one page, its regions are read whatever the page is named.
+func loadSpineAtlas(filename string, imageFilename string) ([]atlasFrame, error) {
+	file, openErr := os.Open(filename)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+
+	type page struct {
+		name   string
+		frames []atlasFrame
+	}
+	var pages []*page
+	var current *page
+	var regionName string
+	var entries map[string]string
+	flush := func() error {
+		if regionName == "" {
+			return nil
+		}
+		f, regionErr := spineRegion(regionName, entries)
+		if regionErr != nil {
+			return regionErr
+		}
+		current.frames = append(current.frames, f)
+		regionName = ""
+		return nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		key, value, isEntry := strings.Cut(line, ":")
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		switch {
+		case strings.TrimSpace(line) == "":
+			if flushErr := flush(); flushErr != nil {
+				return nil, flushErr
+			}
+			current = nil
+		case current == nil:
+			current = &page{name: strings.TrimSpace(line)}
+			pages = append(pages, current)
+		case isEntry && (indented || regionName != ""):
+			entries[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		case isEntry:
+			// page properties like size, format and filter
+		default:
+			if flushErr := flush(); flushErr != nil {
+				return nil, flushErr
+			}
+			regionName, entries = strings.TrimSpace(line), make(map[string]string)
+		}
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, scanErr
+	}
+	if flushErr := flush(); flushErr != nil {
+		return nil, flushErr
+	}
+
+	if len(pages) == 1 {
+		return pages[0].frames, nil
+	}
+	for _, p := range pages {
+		if p.name == path.Base(imageFilename) {
+			return p.frames, nil
+		}
+	}
+	return nil, fmt.Errorf("no page %s", path.Base(imageFilename))
+}
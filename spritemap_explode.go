@@ -7,11 +7,18 @@ import (
 	"fmt"
 	"os"
 	"image/png"
+	"image/color"
 	_ "image/jpeg"
 	_ "image/gif"
 	"strings"
-	"math"
+	"text/template"
 	"path"
+	"slices"
+
+	"github.com/hschendel/spritemap-explode/spritemapexplode"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
 type SpriteMap interface {
@@ -19,78 +26,174 @@ type SpriteMap interface {
 	SubImage(r image.Rectangle) image.Image
 }
 
-func imageEmpty(img image.Image) bool {
-	for x := 0; x < img.Bounds().Max.X; x++ {
-		for y := 0; y < img.Bounds().Max.Y; y++ {
-			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
-				return false
-			}
-		}
-	}
-	return true
-}
-
-func imageMirrorY(img image.Image) image.Image {
-	mirrorImg := image.NewNRGBA(img.Bounds())
-	mx := img.Bounds().Max.X
-	for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
-		mx--
-		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
-			mirrorImg.Set(mx, y, img.At(x, y))
-		}
-	}
-	return mirrorImg
+// imageTransforms maps transform names that can be requested per frame to their implementation.
+var imageTransforms = map[string]func(image.Image) image.Image{
+	"":          func(img image.Image) image.Image { return img },
+	"mirror-y":  spritemapexplode.MirrorY,
+	"mirror-x":  spritemapexplode.MirrorX,
+	"fliph":     spritemapexplode.MirrorY,
+	"flipv":     spritemapexplode.MirrorX,
+	"rotate90":  imageRotate90,
+	"rotate180": imageRotate180,
+	"rotate270": imageRotate270,
 }
 
 type args struct {
-	Filename    string
-	Prefix      string
-	Suffix      string
-	FrameWidth  uint
-	FrameHeight uint
-	Columns     uint
-	Rows        uint
-	MirrorLeft  bool
-}
+	Filename       string
+	Filenames      []string
+	Prefix         string
+	Suffix         string
+	FrameWidth     uint
+	FrameHeight    uint
+	Columns        uint
+	Rows           uint
+	MirrorLeft     bool
+	MirrorDown     bool
+	MirrorUp       bool
+	MirrorAll      bool
+	NormalMap      bool
+	Scale          uint
+	ScaleFilter    string
+	Resolutions    resolutionList
+	POT            bool
+	POTAnchor      string
+	Extrude        uint
+	PreserveDepth  bool
+	QuantizeShared uint
+	Palette        string
+	Rotations      rotationList
+	MirrorNames    tokenMap
+	MirrorPosition string
+	Script         string
+	TransformSpec  string
+	GoPackage      string
+	Webhook        string
+	Aseprite       string
+	AsepriteLayer  string
+	Tiles          string
+	TilesDedup     bool
+	MaxColors      uint
+	ColorBlock     uint
+	ColorsStrict   bool
+	C64Sprites     string
+	Amiga          string
+	DebugOverlay   string
+	HTMLPreview    string
+	Preview        string
+	TUI            bool
+	SkipOverlay    string
+	RowPreviews    bool
+	FPS            fpsFlag
+	OnionSkin      bool
+	Montage        string
+	MontageCols    uint
+	Rects          rectList
+	Extract        cellIndex
+	Animations     animationList
+	Charmap        string
+	CharmapFile    string
+	Codepoints     string
+	AutoCrop       bool
+	Merge          bool
+	MaxFrameBytes  int64
+	MaxTotalBytes  int64
+	Checksums      string
+	Golden         string
+	RowHeights     sizeList
+	ColumnWidths   sizeList
+	Flatten        colorFlag
+	Underlay       string
+	Watermark      string
+	Dilate         bool
+	RowsBottomUp   bool
+	Frames         uint
+	Output         string
+	Pack           string
+	PackColumns    uint
+	PackPadding    uint
+	PackSize       string
+	PackData       string
+	Margin         uint
+	Spacing        uint
+	OffsetX        uint
+	OffsetY        uint
+	OutDir         string
+	NameTemplate   string
+	Format         string
+	Temporal       bool
+	RowAPNG        bool
+	Manifest       string
+	Atlas          string
+	TPS            string
+	Export         exportList
+	Pivot          string
+	AsepriteJSON   string
+	Index          bool
+	Trim           bool
+	AutoGrid       bool
+	Detect         bool
+	Separators     bool
+	SeparatorColor colorFlag
+	KeepEmpty      bool
+	EmptyThreshold emptyThreshold
+	Background     colorFlag
+	ColorKey       colorKeyFlag
+	Numbering      string
+	StartIndex     uint
+	PadDigits      uint
+	RowNames       rowNameList
+	Names          string
+	RowDirs        bool
+	RectsFile      string
+	Exclude        excludeList
 
-func (a *args) ImageColumns(img SpriteMap) int {
-	if a.Columns != 0 {
-		return int(a.Columns)
-	}
-	return img.Bounds().Max.X / int(a.FrameWidth)
-}
+	underlay  image.Image
+	watermark image.Image
+	palette   color.Palette
 
-func (a *args) ImageRows(img SpriteMap) int {
-	if a.Rows != 0 {
-		return int(a.Rows)
-	}
-	return img.Bounds().Max.Y / int(a.FrameHeight)
+	nameTemplate *template.Template
 }
 
-func (a *args) ImageFrameWidth(img SpriteMap) int {
-	if a.FrameWidth != 0 {
-		return int(a.FrameWidth)
+// numberingModes are the supported values of -numbering.
+var numberingModes = []string{"rowcol", "colrow", "linear"}
+
+// FrameIndexFormat returns the format of the <row index>-<column index> part of the frame file
+// names, padding the indices to the same number of digits. With -numbering colrow the column
+// index comes first, and with linear there is only the index of the cell in reading order.
+func (a *args) FrameIndexFormat(rows, columns, cells int) string {
+	xDigits := a.indexDigits(columns)
+	yDigits := a.indexDigits(rows)
+
+	switch a.Numbering {
+	case "linear":
+		return "%0" + strconv.Itoa(a.indexDigits(cells)) + "d"
+	case "colrow":
+		return "%0" + strconv.Itoa(xDigits) + "d-%0" + strconv.Itoa(yDigits) + "d"
 	}
-	return img.Bounds().Max.X / int(a.Columns)
+	return "%0" + strconv.Itoa(yDigits) + "d-%0" + strconv.Itoa(xDigits) + "d"
 }
 
-func (a *args) ImageFrameHeight(img SpriteMap) int {
-	if a.FrameHeight != 0 {
-		return int(a.FrameHeight)
+// indexDigits returns the number of digits of the largest of count indices counted from
+// -start-index, or the fixed number of -pad-digits.
+func (a *args) indexDigits(count int) int {
+	if a.PadDigits != 0 {
+		return int(a.PadDigits)
 	}
-	return img.Bounds().Max.Y / int(a.Rows)
+	return len(strconv.Itoa(max(count-1, 0) + int(a.StartIndex)))
 }
 
-func (a *args) FrameFilenameFormat(img SpriteMap) string {
-	xDigits := int(math.Ceil(math.Log10(float64(a.ImageColumns(img)))))
-	yDigits := int(math.Ceil(math.Log10(float64(a.ImageRows(img)))))
-
-	format := "-%0" + strconv.Itoa(yDigits) + "d-%0" + strconv.Itoa(xDigits) + "d.png"
-	if a.MirrorLeft {
-		format = "-%s" + format
+// frameIndex formats the index part of the file name of the cell at position i in reading order
+// with a format returned by FrameIndexFormat, counting from -start-index.
+func (a *args) frameIndex(format string, i int, row int, column int) string {
+	start := int(a.StartIndex)
+	i, row, column = i+start, row+start, column+start
+	switch a.Numbering {
+	case "linear":
+		return fmt.Sprintf(format, i)
+	case "colrow":
+		return fmt.Sprintf(format, column, row)
 	}
-	format = "%s" + format
-	return format
+	return fmt.Sprintf(format, row, column)
 }
 
 func (a *args) parse() bool {
@@ -100,82 +203,926 @@ func (a *args) parse() bool {
 	flag.UintVar(&a.Rows, "rows", 0, "Fumber of rows. Frame height is calculated by dividing the source image height by this number.")
 	flag.BoolVar(&a.MirrorLeft, "mirror-left", false, "Every frame is duplicated and flipped on the y axis, i.e. facing left if it has been facing right before."+
 		" The file name scheme is then extended to <prefix>-<l|r>-<row index>-<column index> with r being the original.")
+	flag.BoolVar(&a.MirrorDown, "mirror-down", false, "Every frame is duplicated and flipped on the x axis, i.e. facing down if it has been facing up before."+
+		" The file name scheme is then extended like with -mirror-left, using u for the original and d for the flipped frame."+
+		" Together with -mirror-left the tokens are combined, e.g. r-u.")
+	flag.BoolVar(&a.MirrorUp, "mirror-up", false, "Like -mirror-down for frames facing down, i.e. every frame is duplicated and flipped on"+
+		" the x axis, using d for the original and u for the flipped frame.")
+	flag.BoolVar(&a.MirrorAll, "mirror-all", false, "Same as -mirror-left together with -mirror-down, writing the original frame and"+
+		" its copies flipped on the y axis, the x axis and both, e.g. <prefix>-r-u-0-0.png, -l-u-, -r-d- and -l-d-.")
+	flag.Var(&a.Rotations, "rotate", "Also write copies of every frame rotated clockwise by these angles, e.g. 90,180,270. The"+
+		" file name scheme is then extended like with -mirror-left, using rot90, rot180 and rot270 for the rotated frames.")
+	flag.BoolVar(&a.NormalMap, "normal-map", false, "The sprite map is a normal map: mirrored frames get the red or green channel inverted"+
+		" as well, so that they are lit correctly. Frames rotated by 90 or 270 degrees are not corrected.")
+	flag.Var(&a.MirrorNames, "mirror-names", "Rename the mirror variant and rotation tokens in file names, e.g. r=right,l=left,rot90=east.")
+	flag.StringVar(&a.MirrorPosition, "mirror-position", "before", "Where the mirror variant token goes: before or after the indices,"+
+		" or dir to write every variant into a subdirectory of that name.")
+	flag.StringVar(&a.Script, "script", "", "Starlark script defining a function frame(f) that is called for every frame with f.row, f.column,"+
+		" f.empty and f.bounds. It may return None for the default behaviour, a bool telling whether to keep the frame,"+
+		" a string to rename it, or a dict with the keys \"skip\", \"name\" and \"transform\" (\"mirror-y\" or \"fliph\", \"mirror-x\" or \"flipv\","+
+		" \"rotate90\", \"rotate180\" or \"rotate270\").")
+	flag.StringVar(&a.TransformSpec, "transforms", "", "File mapping cells to transforms applied before writing them, one per line like"+
+		" \"2:3 = rotate90\", \"row 4 = fliph\" or \"column 0 = flipv\", with the transform names of -script.")
+	flag.StringVar(&a.GoPackage, "go-package", "", "Write the frames into this directory and generate a frames.go file there that embeds them"+
+		" and maps animation name, or row index if the row is unnamed, and frame to the file names. Meant to be used with go:generate.")
+	flag.StringVar(&a.Webhook, "webhook", "", "URL to POST a JSON summary of the written frames to once the sprite map has been processed.")
+
+	flag.StringVar(&a.Aseprite, "aseprite", "", "Aseprite executable used to export .ase and .aseprite files, e.g. aseprite. By default such"+
+		" files are read directly, drawing all layers with normal blending. The frames of"+
+		" such files are named <prefix>-<tag>-<index in tag>, or <prefix>-<frame index> if the file has no tags.")
+	flag.StringVar(&a.AsepriteLayer, "aseprite-layer", "", "Only export this layer of .ase and .aseprite files instead of all visible layers.")
+	flag.StringVar(&a.Tiles, "tiles", "", "Also write every frame as tile data for a retro target, next to the PNG file. Supported targets: "+tileFormatNames()+"."+
+		" For Game Boy targets, <prefix>.inc defines the first tile index and tile count of every frame. For GBA, SNES and"+
+		" Genesis targets, the 16 color palette of every frame is written into a .pal file in the target's color format."+
+		" genesis-sprite orders the tiles column-major like the hardware expects for sprites.")
+	flag.BoolVar(&a.TilesDedup, "tiles-dedup", false, "Together with -tiles, write the unique tiles of all frames into <prefix>-tileset.<ext>"+
+		" and a tile map referencing them into a .map file per frame. Flipped tiles are reused where the target's tile maps support flipping.")
+	flag.UintVar(&a.MaxColors, "max-colors-check", 0, "Warn about frames using more than this number of colors, counting transparency as one color.")
+	flag.UintVar(&a.ColorBlock, "max-colors-block", 0, "Apply -max-colors-check to each block of this size, e.g. 8 or 16, instead of the whole frame.")
+	flag.BoolVar(&a.ColorsStrict, "max-colors-strict", false, "Stop with an error instead of warning when -max-colors-check is exceeded.")
+	flag.StringVar(&a.C64Sprites, "c64-sprites", "", "Also write every frame as C64 sprite data in hires or multicolor mode into a .spr file"+
+		" with one 64 byte record per hardware sprite. Frames larger than 24x21 are split into several sprites, whose offsets"+
+		" are written into a .inc file.")
+	flag.StringVar(&a.Amiga, "amiga", "", "Also write every frame as Amiga bob or sprite data. A bob is written as interleaved bitplanes"+
+		" into a .bpl file and its mask into a .msk file, sprites of 16 pixels width into an .aspr file. The palette is written"+
+		" into a .pal file as 12 bit color register values, which is why -tiles targets with palettes cannot be combined with it.")
+	flag.StringVar(&a.DebugOverlay, "debug-overlay", "", "Write a copy of the sprite map to this file with the outline and index of every frame"+
+		" drawn on top and empty frames shaded, to check the grid arguments.")
+	flag.StringVar(&a.HTMLPreview, "html", "", "Write an HTML page to this file showing every written frame with its name, row, column, size and source"+
+		" rectangle, and every row played back as an animation.")
+	flag.StringVar(&a.Preview, "preview", "", "Serve an HTML preview of the frames on this address, e.g. localhost:8080. The sprite map"+
+		" is exploded again whenever it changes, and the page reloads automatically.")
+	flag.BoolVar(&a.TUI, "tui", false, "Show the sprite map with the grid in the terminal and adjust the frame size with the arrow keys,"+
+		" the margin with m and M, the spacing with s and S and the offset with x, X, y and Y,"+
+		" then explode with the chosen grid or print the matching arguments.")
+	flag.StringVar(&a.SkipOverlay, "skip-overlay", "", "Write a copy of the sprite map to this file with the written frames tinted green"+
+		" and the skipped ones tinted red, to spot frames that have been dropped unexpectedly.")
+	flag.BoolVar(&a.RowPreviews, "row-previews", false, "Also write an animated GIF of every row into the previews directory next to the frames.")
+	flag.BoolVar(&a.RowPreviews, "preview-gif", false, "Same as -row-previews.")
+	a.FPS = fpsFlag{Default: 10}
+	flag.Var(&a.FPS, "fps", "Frames per second of animations, either for all rows or for a single row given as row=fps, e.g. 0=12."+
+		" Can be repeated. Frames of Aseprite files keep their own duration unless the fps of their row is given.")
+	flag.BoolVar(&a.OnionSkin, "onion-skin", false, "Also write a composite of all frames of every row at reduced opacity into the previews directory.")
+	flag.StringVar(&a.Montage, "montage", "", "Instead of exploding, lay out all images of the directory given as <filename> on a labeled"+
+		" contact sheet and write it to this file.")
+	flag.UintVar(&a.MontageCols, "montage-columns", 0, "Number of columns of the -montage contact sheet. By default it is roughly square.")
+	flag.Var(&a.Rects, "rect", "Cut the region name=x,y,w,h into <prefix>-<name>.png instead of using a grid. Can be repeated.")
+	flag.Var(&a.Extract, "extract", "Instead of exploding, write only the frame at row,column to the -o file.")
+	flag.Var(&a.Extract, "frame", "Same as -extract.")
+	flag.StringVar(&a.Palette, "palette", "", "Instead of exploding, write the unique colors of the sprite map to this file, as a GIMP"+
+		" palette if it ends with .gpl or a JASC palette if it ends with .pal, to load them into an image editor.")
+	flag.StringVar(&a.Output, "o", "-", "Output file of -extract, in the format of its extension, or - for PNG on stdout.")
+	flag.Var(&a.Animations, "anim", "Define an animation spanning whole rows as name=rows:first-last or a range of cells in reading order as"+
+		" name=cells:row:column-row:column. Can be repeated. Only the frames of the animations are written then, named"+
+		" <prefix>-<name>-<index in animation>, and every animation counts as a row, e.g. for -fps.")
+	flag.StringVar(&a.Charmap, "charmap", "", "Name the frames of a bitmap font sheet <prefix>-<character>.png by these characters,"+
+		" assigned to the frames in reading order with empty frames using up a character too. Upper case letters are named"+
+		" like upper_A, characters other than ASCII letters and digits by their code point, e.g. 0x263A. Frames beyond the"+
+		" last character are left out.")
+	flag.StringVar(&a.CharmapFile, "charmap-file", "", "Like -charmap, but read the characters from this file, ignoring line breaks.")
+	flag.StringVar(&a.Codepoints, "codepoints", "", "File listing the code points of an emoji or icon sheet in reading order, like"+
+		" 1F600 or U+1F468-200D-1F469. The frames are named <prefix>-u<code points>.png like -charmap does, and"+
+		" <prefix>-codepoints.json maps every code point to its file.")
+	flag.BoolVar(&a.AutoCrop, "autocrop", false, "Trim fully transparent outer rows and columns of the sprite map before applying the grid,"+
+		" e.g. to ignore padding around the sheet when using -columns and -rows.")
+	flag.BoolVar(&a.Merge, "merge", false, "Explode all sprite maps given as arguments as if they were stacked into one, continuing the"+
+		" row indices from one file to the next. The frames are named after the first file. Every sprite map is cut by the same"+
+		" grid, so the frames cannot be given by rectangles, detection or atlases.")
+	flag.Int64Var(&a.MaxFrameBytes, "max-frame-bytes", 0, "Fail with a report of all frame sizes if a written frame file is larger than this.")
+	flag.Int64Var(&a.MaxTotalBytes, "max-total-bytes", 0, "Fail with a report of all frame sizes if the written frame files together are larger than this.")
+	flag.StringVar(&a.Checksums, "checksums", "", "Write the SHA-256 sums of all files written by the run"+
+		" to this file, e.g. SHA256SUMS, to be verified with sha256sum -c.")
+	flag.StringVar(&a.Golden, "golden", "", "Instead of writing the frames, compare them pixel by pixel against the files of the same name"+
+		" in this directory and report every mismatch, missing and extra frame.")
+	flag.Var(&a.RowHeights, "row-heights", "Heights of the rows from top to bottom, e.g. 32,48,32, for sheets whose rows differ in height. Replaces -height and -rows.")
+	flag.Var(&a.ColumnWidths, "col-widths", "Widths of the columns from left to right, e.g. 24,24,48, for sheets whose columns differ in width. Replaces -width and -columns.")
+	flag.UintVar(&a.Scale, "scale", 1, "Enlarge every frame by this integer factor, e.g. 2 or 4, repeating every pixel like"+
+		" nearest neighbor scaling does, to keep pixel art sharp.")
+	flag.StringVar(&a.ScaleFilter, "scale-filter", "nearest", "How -scale enlarges the frames: "+scaleFilterNames()+". scale2x and"+
+		" scale3x smooth diagonal edges of pixel art without adding colors, for factors that are powers of 2 or 3.")
+	flag.Var(&a.Resolutions, "resolutions", "Also write every frame at these scales, e.g. 2x,0.5x, into subdirectories named after"+
+		" the scale next to the frames. 1x stands for the frames themselves. Enlarging by whole numbers works like -scale.")
+	flag.UintVar(&a.Extrude, "extrude", 0, "Grow every frame by this number of pixels on each side, repeating its border pixels,"+
+		" so that neighbors in a mipmapped atlas do not bleed into it.")
+	flag.BoolVar(&a.POT, "pot", false, "Pad every frame with transparent pixels to the next power of two in width and height, e.g."+
+		" 24x40 to 32x64, for engines and GPUs requiring such texture sizes.")
+	flag.StringVar(&a.POTAnchor, "pot-anchor", "top-left", "Where -pot places the frame within the padded area: "+anchorNames()+".")
+	flag.BoolVar(&a.PreserveDepth, "preserve-depth", true, "Keep 16 bits per channel of sprite maps like 16 bit PNGs in PNG and"+
+		" TIFF frames, including their mirrored and rotated copies. Use -preserve-depth=false to write them with 8 bits."+
+		" Frames of other formats and of -quantize-shared always have 8 bits.")
+	flag.UintVar(&a.QuantizeShared, "quantize-shared", 0, "Reduce the colors of all frames to one shared palette of at most this"+
+		" number of colors, up to 256, and write them as indexed PNGs, so that all frames use exactly the same colors.")
+	flag.Var(&a.Flatten, "flatten", "Composite every frame over this color, e.g. '#303030', removing transparency.")
+	flag.StringVar(&a.Underlay, "underlay", "", "Draw every frame over this image, e.g. a checkerboard or stage background, repeated to cover the frame.")
+	flag.StringVar(&a.Watermark, "watermark", "", "Draw this image centered over every frame, e.g. for public previews of unreleased art.")
+	flag.BoolVar(&a.Dilate, "dilate", false, "Fill the color of fully transparent pixels from the nearest visible pixels while keeping them"+
+		" transparent, to avoid dark halos when the frames are scaled or mipmapped by an engine.")
+	flag.BoolVar(&a.Dilate, "alpha-bleed", false, "Same as -dilate.")
+	flag.BoolVar(&a.RowsBottomUp, "rows-bottom-up", false, "Number and traverse the rows starting with the bottom one, for sheets authored with a bottom left origin.")
+	flag.UintVar(&a.Frames, "frames", 0, "Number of frames in the sprite map. Together with the frame size or one of -columns and -rows,"+
+		" the missing number of columns or rows is derived from it. Frames after this number are ignored.")
+	flag.StringVar(&a.Pack, "pack", "", "Instead of exploding, pack all images of the directory given as <filename> in the order of their"+
+		" names into a sprite map and write it to this file. Every frame goes into the top left corner of a cell of the size of the largest frame.")
+	flag.UintVar(&a.PackColumns, "pack-columns", 0, "Number of columns of the -pack sprite map. By default it is roughly square.")
+	flag.UintVar(&a.PackPadding, "pack-padding", 0, "Pixels between the cells of the -pack sprite map.")
+	flag.StringVar(&a.PackSize, "pack-size", "", "Size of the -pack sprite map as <width>x<height>, e.g. 256x256. By default it is as large as needed.")
+	flag.UintVar(&a.Margin, "margin", 0, "Pixels around the grid of the sprite map that do not belong to any frame.")
+	flag.UintVar(&a.Spacing, "spacing", 0, "Pixels between neighboring frames of the sprite map, e.g. the padding of -pack.")
+	flag.UintVar(&a.OffsetX, "offset-x", 0, "Pixels to skip on the left of the sprite map before the grid starts.")
+	flag.UintVar(&a.OffsetY, "offset-y", 0, "Pixels to skip at the top of the sprite map before the grid starts, e.g. a header strip.")
+	flag.StringVar(&a.OutDir, "out", "", "Write the frames and the files derived from their names into this directory instead of next to"+
+		" the sprite map, creating it if missing.")
+	flag.StringVar(&a.NameTemplate, "name-template", "", "Go text/template for the frame file names without extension, instead of"+
+		" <prefix>-<row index>-<column index>. Available are {{.Row}}, {{.Col}}, {{.Index}} (the position of the cell in reading order),"+
+		" {{.Mirror}}, {{.Name}} (the name of aseprite, charmap or animation frames) and {{.Base}} (the sprite map file name without suffix),"+
+		" e.g. {{.Base}}_{{printf \"%03d\" .Index}}{{with .Mirror}}_{{.}}{{end}}.")
+	flag.StringVar(&a.Format, "format", "png", "File format of the frames: png, gif, bmp, jpeg, tiff or webp (lossless). JPEG has no transparency, see -flatten.")
+	flag.BoolVar(&a.Temporal, "temporal", false, "Explode the frames of an animated GIF instead of a grid. Partial frames are"+
+		" composed according to their disposal methods, so every frame is written as it is shown, with the GIF frame delays as durations.")
+	flag.BoolVar(&a.RowAPNG, "row-apng", false, "Also write an animated PNG of every row into the previews directory, timed like -row-previews.")
+	flag.StringVar(&a.Manifest, "manifest", "", "Write a JSON manifest to this file, e.g. manifest.json, listing every written frame with its file name,"+
+		" source rectangle, row, column and mirror variant, as well as the skipped cells and whether they were empty.")
+	flag.StringVar(&a.Atlas, "atlas", "", "TexturePacker JSON atlas in the hash or array format, or Spine .atlas file, describing the frames of the sprite map."+
+		" Every frame is written with its name, rotated back and with its trimmed border restored.")
+	flag.StringVar(&a.TPS, "tps", "", "TexturePacker .tps project file whose settings are used for the arguments not given: its data"+
+		" file as -atlas, its trim mode as -trim and the scales of its variants relative to the first as -resolutions. Without"+
+		" <filename> its texture is exploded. If <filename> is a directory, it is packed into the texture with the shape"+
+		" padding as -pack-padding, and a JSON data file is written like -export texturepacker.")
+	flag.Var(&a.Export, "export", "Also describe the frames as regions of the sprite map for game engines, given as a comma separated list"+
+		" or repeatedly. Supported are "+exportFormatNames()+". With -pack, the packed sprite map is described. unity writes"+
+		" <sprite map>.meta next to the sprite map instead of into -out, as Unity only finds it there.")
+	flag.StringVar(&a.Pivot, "pivot", "center", "Pivot of the frames in -export texturepacker and phaser3, relative to the untrimmed frame: "+anchorNames()+".")
+	flag.StringVar(&a.AsepriteJSON, "aseprite-json", "", "JSON data file exported by Aseprite together with the sprite map. Its frames"+
+		" are named and grouped by their tags like those of .ase files, and every slice is written as <prefix>-<slice name>.")
+	flag.BoolVar(&a.Index, "index", false, "Write the page of -html as index.html into the directory of the frames, as a contact sheet to review them.")
+	flag.BoolVar(&a.Trim, "trim", false, "Crop every written frame to its visible pixels. The size before and the offset of the"+
+		" remaining part are listed in the -manifest and -export. Frames are trimmed after -scale and before -extrude, -pot and the"+
+		" other steps changing the written frames, so that these apply to the trimmed frame.")
+	flag.BoolVar(&a.AutoGrid, "auto-grid", false, "Infer the frame width and height, -spacing, -margin and offset from the fully"+
+		" transparent rows and columns between the frames instead of setting them. The frames are fitted as tightly around"+
+		" the sprites as the gutters allow.")
+	flag.BoolVar(&a.Detect, "detect", false, "Instead of using a grid, find every sprite as a group of connected pixels that are not"+
+		" fully transparent and write it cropped to its bounding box. Sprites side by side form a row and are numbered from left to right.")
+	flag.BoolVar(&a.Separators, "separators", false, "Instead of using a grid, slice the sprite map along the rows and columns drawn in a"+
+		" single color to separate the frames. The color is the most frequent one of such lines unless -separator-color is given.")
+	flag.Var(&a.SeparatorColor, "separator-color", "Color of the lines separating the frames, e.g. '#ff00ff'. Implies -separators.")
+	flag.BoolVar(&a.KeepEmpty, "keep-empty", false, "Also write the fully transparent frames instead of omitting them, e.g. to keep"+
+		" the frame numbering of an engine import contiguous.")
+	flag.Var(&a.EmptyThreshold, "empty-threshold", "Count frames as empty that have no pixel more opaque than this alpha value, e.g."+
+		" 8, or at most as many such pixels as given after a comma, e.g. 8,3, to skip frames with stray pixels.")
+	flag.Var(&a.Background, "background", "Background color of sheets without transparency, e.g. '#ff00ff'. Frames of only that color"+
+		" count as empty.")
+	flag.Var(&a.ColorKey, "colorkey", "Make this color of the sprite map transparent, e.g. '#ff00ff' for magenta sheets. A tolerance"+
+		" for the difference of every channel may follow after a colon, e.g. '#00ff00:16' for green screens.")
+	flag.StringVar(&a.Numbering, "numbering", "rowcol", "How the frame files are numbered: rowcol for <row index>-<column index>,"+
+		" colrow for <column index>-<row index>, or linear for a single index counting the cells in reading order.")
+	flag.UintVar(&a.StartIndex, "start-index", 0, "Number of the first row, column or frame in the file names and -name-template, e.g. 1 for"+
+		" engines and naming conventions counting from 1.")
+	flag.UintVar(&a.PadDigits, "pad-digits", 0, "Pad the indices in the file names with zeros to this number of digits, so that the"+
+		" files of sheets of different sizes sort alike. By default the indices are padded to the digits of the largest one.")
+	flag.Var(&a.RowNames, "row-names", "Name the rows from the top as animations, e.g. idle,walk,run, so that the frames are written"+
+		" as <prefix>-<row name>-<column index>.png. Rows without a name keep their index.")
+	flag.StringVar(&a.Names, "names", "", "CSV file naming frames, one per line as row,column,name or as index,name with the index"+
+		" of the cell in reading order, all counted from 0. The frames are written as <prefix>-<name>.png.")
+	flag.BoolVar(&a.RowDirs, "row-dirs", false, "Write the frames of every row into a subdirectory named after the row index, or"+
+		" after the animation of the row, e.g. given by -row-names or -anim.")
+	flag.StringVar(&a.RectsFile, "rects", "", "Like -rect, but read the regions from this file, one per line as x,y,w,h or x,y,w,h,name."+
+		" Regions without a name are written as <prefix>-0-<index of the region in the file>.png.")
+	flag.Var(&a.Exclude, "exclude", "Skip the cells matching these patterns even if they are not empty, e.g. guides or a palette"+
+		" drawn into the sheet. The patterns are matched against <row index>-<column index>, counted from 0, and the frame name,"+
+		" like 0-0,1-*,walk-*, given as a comma separated list or repeatedly.")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [arguments] <filename>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [arguments] <filename>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -merge [arguments] <filename> <filename>...\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "%s creates files for each frame in a sprite map. The new files will be named\n", os.Args[0])
 		fmt.Fprintln(os.Stderr, "using the scheme <prefix>-<row index>-<column index>.png. Empty frames will be")
-		fmt.Fprintln(os.Stderr, "omitted. The rows and columns are counted starting with 0.\n")
+		fmt.Fprintln(os.Stderr, "omitted unless -keep-empty is set. The rows and columns are counted starting with 0.")
+		fmt.Fprintln(os.Stderr)
 		flag.PrintDefaults()
 	}
 
 	flag.Parse()
 
-	if flag.NArg() != 1 {
+	var project tpsProject
+	if a.TPS != "" {
+		var loadErr error
+		if project, loadErr = loadTPS(a.TPS); loadErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot read TexturePacker project", a.TPS+":", loadErr)
+			return false
+		}
+	}
+	if flag.NArg() != 1 && !(a.Merge && flag.NArg() > 1) && !(a.TPS != "" && flag.NArg() == 0 && project.TextureFileName != "") {
 		flag.Usage()
 		return false
 	}
 	a.Filename = flag.Arg(0)
+	a.Filenames = flag.Args()
+	if a.TPS != "" {
+		if a.Filename == "" {
+			a.Filename = project.TextureFileName
+			a.Filenames = []string{a.Filename}
+		}
+		given := map[string]bool{}
+		flag.Visit(func(f *flag.Flag) {
+			given[f.Name] = true
+		})
+		a.applyTPS(project, given)
+	}
 	a.Suffix = path.Ext(a.Filename)
 	a.Prefix = strings.TrimSuffix(a.Filename, a.Suffix)
+	if a.GoPackage != "" {
+		a.Prefix = path.Join(a.GoPackage, path.Base(a.Prefix))
+	} else if a.OutDir != "" {
+		a.Prefix = path.Join(a.OutDir, path.Base(a.Prefix))
+	}
+	if a.Index && a.HTMLPreview == "" {
+		a.HTMLPreview = path.Join(path.Dir(a.Prefix), "index.html")
+	}
+
+	if _, ok := tileFormats[a.Tiles]; a.Tiles != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Unknown tile format %s, supported are %s\n", a.Tiles, tileFormatNames())
+		return false
+	}
+
+	if _, ok := imageFormatExtensions[a.Format]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format %s, supported are %s\n", a.Format, imageFormatNames())
+		return false
+	}
+
+	if a.C64Sprites != "" && a.C64Sprites != "hires" && a.C64Sprites != "multicolor" {
+		fmt.Fprintf(os.Stderr, "Unknown C64 sprite mode %s, supported are hires and multicolor\n", a.C64Sprites)
+		return false
+	}
 
-	if a.FrameHeight == 0 && a.Rows == 0 {
-		os.Stderr.WriteString("Need to set either -height or -rows\n")
+	if a.Amiga != "" && a.Amiga != "bob" && a.Amiga != "sprite" {
+		fmt.Fprintf(os.Stderr, "Unknown Amiga mode %s, supported are bob and sprite\n", a.Amiga)
+		return false
+	}
+	if a.Amiga != "" && tileFormats[a.Tiles].Palette {
+		fmt.Fprintf(os.Stderr, "-amiga and -tiles %s both write the palette of every frame into its .pal file\n", a.Tiles)
+		return false
+	}
+
+	if !slices.Contains(numberingModes, a.Numbering) {
+		fmt.Fprintf(os.Stderr, "Unknown numbering %s, supported are %s\n", a.Numbering, strings.Join(numberingModes, ", "))
+		return false
+	}
+
+	if a.MirrorAll {
+		a.MirrorLeft, a.MirrorDown = true, !a.MirrorUp
+	}
+	if f, ok := scaleFilters[a.ScaleFilter]; ok {
+		if _, stepsErr := f.scaleSteps(int(max(a.Scale, 1))); stepsErr != nil {
+			fmt.Fprintf(os.Stderr, "Cannot scale by %d with %s: %v\n", a.Scale, a.ScaleFilter, stepsErr)
+			return false
+		}
+	} else if a.ScaleFilter != "nearest" {
+		fmt.Fprintf(os.Stderr, "Unknown scale filter %s, supported are %s\n", a.ScaleFilter, scaleFilterNames())
+		return false
+	}
+
+	if _, ok := anchors[a.POTAnchor]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown anchor %s, supported are %s\n", a.POTAnchor, anchorNames())
+		return false
+	}
+	if _, ok := anchors[a.Pivot]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown pivot %s, supported are %s\n", a.Pivot, anchorNames())
+		return false
+	}
+
+	if _, ok := paletteWriters[strings.ToLower(path.Ext(a.Palette))]; a.Palette != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Unknown palette format %s, supported are %s\n", path.Ext(a.Palette), paletteExtensions())
+		return false
+	}
+
+	if a.QuantizeShared == 1 || a.QuantizeShared > 256 {
+		fmt.Fprintf(os.Stderr, "Cannot quantize to %d colors, a palette has 2 to 256 colors\n", a.QuantizeShared)
+		return false
+	}
+
+	if a.MirrorDown && a.MirrorUp {
+		os.Stderr.WriteString("Use either -mirror-down or -mirror-up\n")
+		return false
+	}
+
+	if !slices.Contains(mirrorPositions, a.MirrorPosition) {
+		fmt.Fprintf(os.Stderr, "Unknown mirror position %s, supported are %s\n", a.MirrorPosition, strings.Join(mirrorPositions, ", "))
+		return false
+	}
+
+	if a.NameTemplate != "" {
+		var templateErr error
+		if a.nameTemplate, templateErr = parseNameTemplate(a.NameTemplate); templateErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot parse name template:", templateErr)
+			return false
+		}
+	}
+
+	if a.Merge && (len(a.Rects) > 0 || a.RectsFile != "" || a.Detect || a.Separators || a.SeparatorColor.Valid || a.AutoGrid ||
+		a.Atlas != "" || a.AsepriteJSON != "" || a.Temporal || isAsepriteFile(a.Filename)) {
+		os.Stderr.WriteString("-merge applies the same grid to every sprite map, which is not possible with -rect, -rects, -detect," +
+			" -separators, -separator-color, -auto-grid, -atlas, -aseprite-json, -temporal or .ase files\n")
+		return false
+	}
+
+	if len(a.Export) > 0 && a.Pack == "" && (isAsepriteFile(a.Filename) || a.Temporal || a.Merge || a.Atlas != "") {
+		os.Stderr.WriteString("-export describes regions of the sprite map file, which is not possible with .ase files, -temporal, -merge or -atlas\n")
+		return false
+	}
+
+	if a.PackSize != "" {
+		if _, sizeErr := parseSize(a.PackSize); sizeErr != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -pack-size", a.PackSize+":", sizeErr)
+			return false
+		}
+	}
+
+	if isAsepriteFile(a.Filename) || a.Temporal || a.Atlas != "" || a.AsepriteJSON != "" || a.TUI || a.Montage != "" || a.Pack != "" || a.Palette != "" || len(a.Rects) > 0 || a.RectsFile != "" || a.AutoGrid || a.Detect || a.Separators || a.SeparatorColor.Valid {
+		return true
+	}
+
+	if a.Frames != 0 && (a.FrameWidth != 0 || a.Columns != 0 || len(a.ColumnWidths) != 0 ||
+		a.FrameHeight != 0 || a.Rows != 0 || len(a.RowHeights) != 0) {
+		return true
+	}
+
+	if a.FrameHeight == 0 && a.Rows == 0 && len(a.RowHeights) == 0 {
+		os.Stderr.WriteString("Need to set either -height, -rows or -row-heights\n")
 		flag.Usage()
 		return false
 	}
 
-	if a.FrameWidth == 0 && a.Columns == 0 {
-		os.Stderr.WriteString("Need to set either -width or -columns\n")
+	if a.FrameWidth == 0 && a.Columns == 0 && len(a.ColumnWidths) == 0 {
+		os.Stderr.WriteString("Need to set either -width, -columns or -col-widths\n")
 		return false
 	}
 
 	return true
 }
 
-func saveImage(img image.Image, filename string) {
-	file, createErr := os.Create(filename)
+func saveImage(img image.Image, filename string) bool {
+	file, createErr := createFile(filename)
 	if createErr != nil {
 		fmt.Fprintln(os.Stderr, "Cannot create file", filename + ":", createErr)
-		return
+		return false
+	}
+	encode, ok := imageEncoders[strings.ToLower(path.Ext(filename))]
+	if !ok {
+		encode = png.Encode
 	}
-	encodeErr := png.Encode(file, img)
+	encodeErr := encode(file, img)
 	file.Close()
 	if encodeErr != nil {
 		fmt.Fprintln(os.Stderr, "Cannot encode image into", filename + ":", encodeErr)
 		os.Remove(filename)
+		return false
+	}
+	return true
+}
+
+// frameFile describes a frame file that has been written by explode. Mirror is the token of the
+// mirror variant, which the original frame has too, while Mirrored is only set for the copies.
+// Frames trimmed by -trim have the size Untrimmed before, and Trim is the written part of that.
+type frameFile struct {
+	Filename  string
+	Row       int
+	Column    int
+	Name      string
+	Animation string
+	Mirror    string
+	Mirrored  bool
+	Rect      image.Rectangle
+	Size      image.Point
+	Image     image.Image
+	Duration  int
+	Trim      image.Rectangle
+	Untrimmed image.Point
+}
+
+// cell is a region of the sprite map that becomes a frame. If Name is set, it replaces the
+// <row index>-<column index> part of the file name. If Animation is set, it names the animation
+// the row of the cell stands for. If Duration is set, it is the display duration of the frame in
+// milliseconds.
+type cell struct {
+	Rect      image.Rectangle
+	Row       int
+	Column    int
+	Name      string
+	Animation string
+	Duration  int
+}
+
+// options returns the options of the spritemapexplode package that correspond to a.
+func (a *args) options() spritemapexplode.Options {
+	return spritemapexplode.Options{
+		FrameWidth:   int(a.FrameWidth),
+		FrameHeight:  int(a.FrameHeight),
+		Columns:      int(a.Columns),
+		Rows:         int(a.Rows),
+		RowHeights:   a.RowHeights,
+		ColumnWidths: a.ColumnWidths,
+		Offset:       image.Pt(int(a.OffsetX), int(a.OffsetY)),
+		Margin:       int(a.Margin),
+		Spacing:      int(a.Spacing),
+		RowsBottomUp: a.RowsBottomUp,
+		Frames:       int(a.Frames),
+		KeepEmpty:    a.KeepEmpty,
+		EmptyAlpha:   a.EmptyThreshold.Alpha,
+		EmptyPixels:  a.EmptyThreshold.Pixels,
+		Background:   a.Background.color(),
+		MirrorLeft:   a.MirrorLeft,
+		MirrorDown:   a.MirrorDown,
+		MirrorUp:     a.MirrorUp,
+		Rotations:    a.Rotations,
+		NormalMap:    a.NormalMap,
+		MirrorNames:  a.MirrorNames,
+	}
+}
+
+func (a *args) gridCells(img SpriteMap) ([]cell, error) {
+	grid, gridErr := spritemapexplode.Grid(img.Bounds(), a.options())
+	if gridErr != nil {
+		return nil, gridErr
+	}
+	cells := make([]cell, len(grid))
+	for i, c := range grid {
+		cells[i] = cell{Rect: c.Rect, Row: c.Row, Column: c.Column}
 	}
+	return cells, nil
 }
 
-func explode(a *args, img SpriteMap) {
-	frameWidth := a.ImageFrameWidth(img)
-	frameHeight := a.ImageFrameHeight(img)
-	columns := a.ImageColumns(img)
-	rows := a.ImageRows(img)
-	format := a.FrameFilenameFormat(img)
+// detectCells returns the cells of a sprite map without a regular grid, found by -detect or
+// -separators.
+func (a *args) detectCells(img SpriteMap) ([]spritemapexplode.Cell, error) {
+	if a.Detect {
+		return spritemapexplode.DetectSprites(img)
+	}
+	var separator color.Color = a.SeparatorColor.Color
+	if !a.SeparatorColor.Valid {
+		var colorErr error
+		if separator, colorErr = spritemapexplode.SeparatorColor(img); colorErr != nil {
+			return nil, colorErr
+		}
+	}
+	return spritemapexplode.SeparatedCells(img, separator)
+}
 
-	for row := 0; row < rows; row++ {
-		y := row * frameHeight
-		for column := 0; column < columns ; column++ {
-			x := column * frameWidth
-			subImage := img.SubImage(image.Rect(x, y, x + frameWidth, y + frameHeight))
-			if imageEmpty(subImage){
-				continue
+func explode(a *args, img SpriteMap, cells []cell, script *frameScript, spec *transformSpec) ([]frameFile, error) {
+	var tiles *tileSet
+	if a.Tiles != "" && a.TilesDedup {
+		tiles = newTileSet(tileFormats[a.Tiles])
+	}
+
+	var files []frameFile
+	save := func(img image.Image, filename string, c cell, mirror string, mirrored bool) error {
+		if a.Scale > 1 {
+			img = imageUpscale(img, int(a.Scale), a.ScaleFilter)
+		}
+		var trim image.Rectangle
+		untrimmed := img.Bounds().Size()
+		if a.Trim {
+			img, trim = trimImage(img)
+		}
+		img = a.postProcess(img)
+		if a.needs8Bit() {
+			img = image8Bit(img)
+		}
+		if a.palette != nil {
+			img = imagePaletted(img, a.palette)
+		}
+		frame := frameFile{Trim: trim, Untrimmed: untrimmed, Filename: filename, Row: c.Row, Column: c.Column, Name: c.Name, Animation: c.Animation, Mirror: mirror, Mirrored: mirrored, Rect: c.Rect, Size: img.Bounds().Size(), Image: img, Duration: a.FPS.Duration(c)}
+		if a.Golden != "" {
+			files = append(files, frame)
+			return nil
+		}
+		if mirror != "" && a.MirrorPosition == "dir" || a.nameTemplate != nil || a.RowDirs {
+			if mkdirErr := os.MkdirAll(path.Dir(filename), 0755); mkdirErr != nil {
+				return mkdirErr
+			}
+		}
+		if !saveImage(img, filename) {
+			return fmt.Errorf("cannot write %s", filename)
+		}
+		files = append(files, frame)
+		if resolutionsErr := a.saveResolutions(img, filename); resolutionsErr != nil {
+			return resolutionsErr
+		}
+		if tiles != nil {
+			if tilesErr := tiles.add(img, filename); tilesErr != nil {
+				return tilesErr
+			}
+		} else if a.Tiles != "" {
+			if tilesErr := saveTiles(img, tileFormats[a.Tiles], filename); tilesErr != nil {
+				return tilesErr
 			}
+		}
+		if a.C64Sprites != "" {
+			if c64Err := saveC64Sprites(img, a.C64Sprites == "multicolor", filename); c64Err != nil {
+				return c64Err
+			}
+		}
+		if a.Amiga != "" {
+			return saveAmiga(img, a.Amiga, filename)
+		}
+		return nil
+	}
 
-			if a.MirrorLeft {
-				filenameR := fmt.Sprintf(format, a.Prefix, "r", row, column)
-				saveImage(subImage, filenameR)
-				filenameL := fmt.Sprintf(format, a.Prefix, "l", row, column)
-				mirrorImage := imageMirrorY(subImage)
-				saveImage(mirrorImage, filenameL)
+	rows, columns := 0, 0
+	for _, c := range cells {
+		rows = max(rows, c.Row+1)
+		columns = max(columns, c.Column+1)
+	}
+	format := a.FrameIndexFormat(rows, columns, len(cells))
+	start := int(a.StartIndex)
 
-			} else {
-				filename := fmt.Sprintf(format, a.Prefix, row, column)
-				saveImage(subImage, filename)
+	for i, c := range cells {
+		row, column := c.Row, c.Column
+		subImage := img.SubImage(c.Rect)
+		empty := a.frameEmpty(subImage)
+		decision := frameDecision{Skip: empty && !a.KeepEmpty || a.Exclude.excludes(c)}
+		if script != nil {
+			var scriptErr error
+			decision, scriptErr = script.Decide(frameInfo{Row: row, Column: column, Empty: empty, Bounds: subImage.Bounds()}, decision)
+			if scriptErr != nil {
+				return files, fmt.Errorf("script failed on frame %d-%d: %v", row, column, scriptErr)
+			}
+		}
+		if decision.Skip {
+			continue
+		}
+		if spec != nil {
+			subImage = a.transform(spec.Transform(row, column), subImage)
+		}
+		subImage = a.transform(decision.Transform, subImage)
+
+		if a.MaxColors != 0 {
+			for _, violation := range checkColors(subImage, int(a.MaxColors), int(a.ColorBlock)) {
+				if a.ColorsStrict {
+					return files, fmt.Errorf("frame %d-%d %s", row, column, violation)
+				}
+				fmt.Fprintf(os.Stderr, "Frame %d-%d %s\n", row, column, violation)
+			}
+		}
+
+		prefix, index := a.Prefix, c.Name
+		if decision.Name != "" {
+			prefix, index = path.Join(path.Dir(a.Prefix), decision.Name), ""
+		} else if index == "" {
+			index = a.frameIndex(format, i, row, column)
+		}
+		if a.RowDirs {
+			dir := c.Animation
+			if dir == "" {
+				dir = strconv.Itoa(row + start)
 			}
+			prefix = path.Join(path.Dir(prefix), dir, path.Base(prefix))
+		}
+		for j, v := range spritemapexplode.Variants(subImage, a.options()) {
+			filename := a.frameFilename(prefix, index, v.Mirror)
+			if a.nameTemplate != nil && decision.Name == "" {
+				var templateErr error
+				filename, templateErr = a.templateFilename(frameNameData{Row: row + start, Col: column + start, Index: i + start, Mirror: v.Mirror, Name: c.Name, Base: path.Base(a.Prefix)})
+				if templateErr != nil {
+					return files, fmt.Errorf("name template failed on frame %d-%d: %v", row, column, templateErr)
+				}
+			}
+			if saveErr := save(v.Image, filename, c, v.Mirror, j > 0); saveErr != nil {
+				return files, saveErr
+			}
+		}
+	}
+
+	if tiles != nil && a.Golden == "" {
+		tilesetFilename := a.Prefix + "-tileset" + tiles.format.Extension
+		if saveErr := tiles.save(tilesetFilename); saveErr != nil {
+			return files, fmt.Errorf("cannot write tile set %s: %v", tilesetFilename, saveErr)
+		}
+	}
+	return files, nil
+}
+
+// loadedSheet is a decoded sprite map with the cells of its frames, named as requested. Bounds
+// are those of the decoded sprite map file, and empty for sprite maps assembled in memory.
+type loadedSheet struct {
+	SpriteMap  SpriteMap
+	Cells      []cell
+	Bounds     image.Rectangle
+	Codepoints []codepoint
+}
+
+// loadSheet decodes the sprite map of a and finds and names the cells of its frames, so that
+// everything working on frames agrees on them. It returns an exit code, which is 0 on success.
+func (a *args) loadSheet() (loadedSheet, int) {
+	var spriteMap SpriteMap
+	var cells []cell
+	var codepoints []codepoint
+	var sheetBounds image.Rectangle
+	if isAsepriteFile(a.Filename) {
+		var asepriteErr error
+		if a.Aseprite == "" {
+			spriteMap, cells, asepriteErr = loadAseFile(a.Filename, a.AsepriteLayer)
+		} else {
+			spriteMap, cells, asepriteErr = loadAseprite(a.Aseprite, a.Filename, a.AsepriteLayer)
+		}
+		if asepriteErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot read", a.Filename+":", asepriteErr)
+			return loadedSheet{}, 10
+		}
+	} else if a.Temporal {
+		var temporalErr error
+		spriteMap, cells, temporalErr = loadTemporalGIF(a.Filename)
+		if temporalErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot decode", a.Filename, "as animated GIF:", temporalErr)
+			return loadedSheet{}, 3
+		}
+	} else if len(a.Filenames) > 1 {
+		var mergeErr error
+		spriteMap, cells, mergeErr = a.loadMergedSheets()
+		if mergeErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot merge sprite maps:", mergeErr)
+			return loadedSheet{}, 25
+		}
+		spriteMap = a.keySheet(spriteMap)
+	} else {
+		file, openErr := os.Open(a.Filename)
+		if openErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot open", a.Filename + ":", openErr)
+			return loadedSheet{}, 2
+		}
+		defer file.Close()
+
+		img, imageFormat, decodeErr := decodeImage(file, a.Filename)
+		if decodeErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot decode", a.Filename + ":", decodeErr)
+			return loadedSheet{}, 3
+		}
+
+		spriteMap = img.(SpriteMap)
+		sheetBounds = img.Bounds()
+		if spriteMap == nil {
+			fmt.Fprintf(os.Stderr,"Image format %s does not support extracting sub-images\n", imageFormat)
+			return loadedSheet{}, 4
+		}
+		spriteMap = a.keySheet(spriteMap)
+		if a.Atlas == "" {
+			spriteMap = a.cropSheet(spriteMap)
+		}
+		if a.Atlas != "" {
+			var atlasErr error
+			if spriteMap, cells, atlasErr = loadAtlas(a.Atlas, a.Filename, spriteMap); atlasErr != nil {
+				fmt.Fprintln(os.Stderr, "Cannot read atlas", a.Atlas+":", atlasErr)
+				return loadedSheet{}, 35
+			}
+		} else if a.AsepriteJSON != "" {
+			var jsonErr error
+			if cells, jsonErr = loadAsepriteJSON(a.AsepriteJSON); jsonErr != nil {
+				fmt.Fprintln(os.Stderr, "Cannot read Aseprite data file", a.AsepriteJSON+":", jsonErr)
+				return loadedSheet{}, 37
+			}
+		} else if len(a.Rects) > 0 {
+			cells = a.Rects
+		} else if a.RectsFile != "" {
+			var rectsErr error
+			if cells, rectsErr = loadRects(a.RectsFile); rectsErr != nil {
+				fmt.Fprintln(os.Stderr, "Cannot read rectangles", a.RectsFile+":", rectsErr)
+				return loadedSheet{}, 39
+			}
+		} else if a.Detect || a.Separators || a.SeparatorColor.Valid {
+			sprites, detectErr := a.detectCells(spriteMap)
+			if detectErr != nil {
+				fmt.Fprintln(os.Stderr, "Cannot detect the sprites of", a.Filename+":", detectErr)
+				return loadedSheet{}, 31
+			}
+			for _, c := range sprites {
+				cells = append(cells, cell{Rect: c.Rect, Row: c.Row, Column: c.Column})
+			}
+		} else {
+			var gridErr error
+			if a.AutoGrid {
+				grid, detectErr := spritemapexplode.DetectGrid(spriteMap)
+				if detectErr != nil {
+					fmt.Fprintln(os.Stderr, "Cannot detect the grid of", a.Filename+":", detectErr)
+					return loadedSheet{}, 31
+				}
+				a.FrameWidth, a.FrameHeight = uint(grid.FrameWidth), uint(grid.FrameHeight)
+				a.Spacing, a.Margin = uint(grid.Spacing), uint(grid.Margin)
+				a.OffsetX, a.OffsetY = uint(grid.Offset.X), uint(grid.Offset.Y)
+			}
+			if cells, gridErr = a.gridCells(spriteMap); gridErr != nil {
+				fmt.Fprintln(os.Stderr, "Cannot apply the grid to", a.Filename+":", gridErr)
+				return loadedSheet{}, 31
+			}
+		}
+	}
+
+	if !isAsepriteFile(a.Filename) {
+		if a.Charmap != "" || a.CharmapFile != "" {
+			chars, charmapErr := a.loadCharmap()
+			if charmapErr != nil {
+				fmt.Fprintln(os.Stderr, "Cannot read character map", a.CharmapFile+":", charmapErr)
+				return loadedSheet{}, 22
+			}
+			cells = charmapCells(cells, chars)
+		}
+		if a.Codepoints != "" {
+			var codepointsErr error
+			codepoints, codepointsErr = loadCodepoints(a.Codepoints)
+			if codepointsErr != nil {
+				fmt.Fprintln(os.Stderr, "Cannot read code points", a.Codepoints+":", codepointsErr)
+				return loadedSheet{}, 23
+			}
+			cells = codepointCells(cells, codepoints)
+		}
+		if len(a.Animations) > 0 {
+			cells = a.Animations.cells(cells)
+		}
+		if len(a.RowNames) > 0 {
+			cells = a.rowNameCells(cells)
+		}
+		if a.Names != "" {
+			names, namesErr := loadFrameNames(a.Names)
+			if namesErr != nil {
+				fmt.Fprintln(os.Stderr, "Cannot read frame names", a.Names+":", namesErr)
+				return loadedSheet{}, 38
+			}
+			cells = names.cells(cells)
+		}
+	}
+
+	return loadedSheet{SpriteMap: spriteMap, Cells: cells, Bounds: sheetBounds, Codepoints: codepoints}, 0
+}
+
+// process explodes the sprite map and writes all requested outputs. It returns the written frames
+// and an exit code, which is 0 on success.
+func process(a *args) ([]frameFile, int) {
+	writtenFiles = nil
+	sheet, exitCode := a.loadSheet()
+	if exitCode != 0 {
+		return nil, exitCode
+	}
+	spriteMap, cells, codepoints, sheetBounds := sheet.SpriteMap, sheet.Cells, sheet.Codepoints, sheet.Bounds
+
+	if a.DebugOverlay != "" {
+		if !saveImage(debugOverlay(spriteMap, cells, a.frameEmpty), a.DebugOverlay) {
+			return nil, 12
+		}
+	}
+
+	var script *frameScript
+	if a.Script != "" {
+		var scriptErr error
+		script, scriptErr = loadFrameScript(a.Script)
+		if scriptErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot load script", a.Script+":", scriptErr)
+			return nil, 5
+		}
+	}
+
+	if a.Underlay != "" {
+		var underlayErr error
+		if a.underlay, underlayErr = loadImage(a.Underlay); underlayErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot load underlay", a.Underlay+":", underlayErr)
+			return nil, 30
+		}
+	}
+	if a.Watermark != "" {
+		var watermarkErr error
+		if a.watermark, watermarkErr = loadImage(a.Watermark); watermarkErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot load watermark", a.Watermark+":", watermarkErr)
+			return nil, 30
+		}
+	}
+
+	if a.QuantizeShared > 0 {
+		a.palette = sharedPalette(spriteMap, cells, int(a.QuantizeShared))
+	}
+
+	var spec *transformSpec
+	if a.TransformSpec != "" {
+		var specErr error
+		spec, specErr = loadTransformSpec(a.TransformSpec)
+		if specErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot load transforms", a.TransformSpec+":", specErr)
+			return nil, 21
+		}
+	}
+
+	if (a.GoPackage != "" || a.OutDir != "") && a.Golden == "" {
+		if mkdirErr := os.MkdirAll(path.Dir(a.Prefix), 0755); mkdirErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot create directory", path.Dir(a.Prefix)+":", mkdirErr)
+			return nil, 7
 		}
 	}
+
+	files, explodeErr := explode(a, spriteMap, cells, script, spec)
+	if a.Golden != "" && explodeErr == nil {
+		mismatches, goldenErr := compareGolden(os.Stderr, a.Golden, path.Dir(a.Prefix), files)
+		if goldenErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot compare with", a.Golden+":", goldenErr)
+			return files, 28
+		}
+		if mismatches != 0 {
+			fmt.Fprintln(os.Stderr, mismatches, "frames differ from", a.Golden)
+			return files, 29
+		}
+		return files, 0
+	}
+	if a.Webhook != "" {
+		if webhookErr := postWebhook(a.Webhook, newWebhookSummary(a.Filename, files, explodeErr)); webhookErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot notify webhook", a.Webhook+":", webhookErr)
+			return files, 9
+		}
+	}
+	if explodeErr != nil {
+		fmt.Fprintln(os.Stderr, "Cannot explode", a.Filename+":", explodeErr)
+		return files, 6
+	}
+
+	if a.MaxFrameBytes != 0 || a.MaxTotalBytes != 0 {
+		if budgetErr := checkSizeBudget(os.Stderr, files, a.MaxFrameBytes, a.MaxTotalBytes); budgetErr != nil {
+			fmt.Fprintln(os.Stderr, "Size budget exceeded:", budgetErr)
+			return files, 26
+		}
+	}
+
+	if a.SkipOverlay != "" {
+		if !saveImage(skipOverlay(spriteMap, cells, files), a.SkipOverlay) {
+			return files, 16
+		}
+	}
+
+	previewDir := path.Join(path.Dir(a.Prefix), "previews")
+	if a.RowPreviews {
+		if previewErr := writeRowPreviews(previewDir, path.Base(a.Prefix), files); previewErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write row previews:", previewErr)
+			return files, 17
+		}
+	}
+	if a.RowAPNG {
+		if apngErr := writeRowAPNGs(previewDir, path.Base(a.Prefix), files); apngErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write row animated PNGs:", apngErr)
+			return files, 33
+		}
+	}
+	if a.OnionSkin {
+		if onionErr := writeOnionSkins(previewDir, path.Base(a.Prefix), files); onionErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write onion skins:", onionErr)
+			return files, 18
+		}
+	}
+
+	if a.Codepoints != "" {
+		mapFilename := a.Prefix + "-codepoints.json"
+		if mapErr := writeCodepointMap(mapFilename, codepoints, files); mapErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write code point map", mapFilename+":", mapErr)
+			return files, 24
+		}
+	}
+
+	if a.Manifest != "" {
+		if manifestErr := writeManifest(a.Manifest, a.Filename, spriteMap, cells, files, a.frameEmpty); manifestErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write manifest", a.Manifest+":", manifestErr)
+			return files, 34
+		}
+	}
+
+	if len(a.Export) > 0 {
+		if exportErr := a.Export.writeExports(newFrameExport(a.Prefix, a.Filename, a.Pivot, sheetBounds, files)); exportErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write export", exportErr)
+			return files, 36
+		}
+	}
+
+	if a.HTMLPreview != "" {
+		if previewErr := writeHTMLPreview(a.HTMLPreview, a.Filename, files); previewErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write HTML preview", a.HTMLPreview+":", previewErr)
+			return files, 13
+		}
+	}
+
+	if format := tileFormats[a.Tiles]; format.AsmInclude && !a.TilesDedup {
+		incFilename := a.Prefix + ".inc"
+		if incErr := writeAsmInclude(incFilename, format, files); incErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write assembly include", incFilename+":", incErr)
+			return files, 11
+		}
+	}
+
+	if a.GoPackage != "" {
+		if generateErr := writeGoPackage(a.GoPackage, files); generateErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot generate Go package", a.GoPackage+":", generateErr)
+			return files, 8
+		}
+	}
+
+	if a.Checksums != "" {
+		if checksumsErr := writeChecksums(a.Checksums, writtenFiles); checksumsErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write checksums", a.Checksums+":", checksumsErr)
+			return files, 27
+		}
+	}
+	return files, 0
 }
 
 func main() {
@@ -184,24 +1131,66 @@ func main() {
 		os.Exit(1)
 	}
 
-	file, openErr := os.Open(args.Filename)
-	if openErr != nil {
-		fmt.Fprintln(os.Stderr, "Cannot open", args.Filename + ":", openErr)
-		os.Exit(2)
+	if args.Montage != "" {
+		if montageErr := writeMontage(args.Filename, args.Montage, int(args.MontageCols)); montageErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot create montage", args.Montage+":", montageErr)
+			os.Exit(19)
+		}
+		return
 	}
-	defer file.Close()
 
-	img, imageFormat, decodeErr := image.Decode(file)
-	if decodeErr != nil {
-		fmt.Fprintln(os.Stderr, "Cannot decode", args.Filename + ":", decodeErr)
-		os.Exit(3)
+	if args.Pack != "" {
+		var size image.Point
+		if args.PackSize != "" {
+			size, _ = parseSize(args.PackSize)
+		}
+		if packErr := writePack(args.Filename, args.Pack, int(args.PackColumns), int(args.PackPadding), size, args.Pivot, args.PackData, args.Export); packErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot pack", args.Pack+":", packErr)
+			os.Exit(32)
+		}
+		return
 	}
 
-	spriteMap := img.(SpriteMap)
-	if spriteMap == nil {
-		fmt.Fprintf(os.Stderr,"Image format %s does not support extracting sub-images\n", imageFormat)
-		os.Exit(4)
+	if args.Extract.Valid {
+		sheet, exitCode := args.loadSheet()
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+		if extractErr := extractFrame(&args, sheet); extractErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot extract frame", args.Extract.String(), "from", args.Filename+":", extractErr)
+			os.Exit(20)
+		}
+		return
+	}
+
+	if args.Palette != "" {
+		if paletteErr := writePalette(&args); paletteErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write palette", args.Palette+":", paletteErr)
+			os.Exit(40)
+		}
+		return
 	}
 
-	explode(&args, spriteMap)
+	if args.TUI {
+		run, tuiErr := runTUI(&args)
+		if tuiErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot run terminal UI:", tuiErr)
+			os.Exit(15)
+		}
+		if !run {
+			return
+		}
+	}
+
+	if args.Preview != "" {
+		if serveErr := servePreview(&args); serveErr != nil {
+			fmt.Fprintln(os.Stderr, "Cannot serve preview on", args.Preview+":", serveErr)
+			os.Exit(14)
+		}
+		return
+	}
+
+	if _, exitCode := process(&args); exitCode != 0 {
+		os.Exit(exitCode)
+	}
 }
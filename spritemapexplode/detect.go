@@ -0,0 +1,389 @@
+package spritemapexplode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// ErrNoSprites is returned if a sprite map has no pixel that is not fully transparent.
+var ErrNoSprites = errors.New("the sprite map is fully transparent")
+
+// ErrNoSeparators is returned if a sprite map has no line of the separator color.
+var ErrNoSeparators = errors.New("no separator lines")
+
+// span is a run of pixels from Start to End, exclusively.
+type span struct {
+	Start int
+	End   int
+}
+
+// transparentLines reports for every column of img, or every row if rows is set, whether all of
+// its pixels are fully transparent.
+func transparentLines(img image.Image, rows bool) []bool {
+	b := img.Bounds()
+	outer, inner := b.Dx(), b.Dy()
+	if rows {
+		outer, inner = inner, outer
+	}
+	transparent := make([]bool, outer)
+	for i := range transparent {
+		transparent[i] = true
+		for j := 0; j < inner && transparent[i]; j++ {
+			x, y := b.Min.X+i, b.Min.Y+j
+			if rows {
+				x, y = b.Min.X+j, b.Min.Y+i
+			}
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				transparent[i] = false
+			}
+		}
+	}
+	return transparent
+}
+
+// ErrIrregularGutters is returned if the fully transparent lines of a sprite map do not divide it
+// into equally sized frames with equal spacing.
+var ErrIrregularGutters = errors.New("the gutters do not divide the sprite map into equally sized frames")
+
+// axisGrid divides the lines of one axis into Frames frames of Size lines, the first starting at
+// Offset, with Spacing lines between them.
+type axisGrid struct {
+	Offset  int
+	Size    int
+	Spacing int
+	Frames  int
+}
+
+// end returns the line after the last frame.
+func (g axisGrid) end() int {
+	return g.Offset + g.Frames*(g.Size+g.Spacing) - g.Spacing
+}
+
+// pitchGrid divides the lines into frames repeating every pitch lines, each holding at least one
+// of the opaque lines and none of them reaching into the spacing or into a neighboring frame. The
+// spacing is the longest run of positions within the pitch that no opaque line falls on, so the
+// frames are as tight as the sprites allow.
+func pitchGrid(transparent []bool, opaque []int, pitch int) (axisGrid, bool) {
+	occupied := make([]bool, pitch)
+	for _, x := range opaque {
+		occupied[x%pitch] = true
+	}
+	// starts are the positions within the pitch a frame may start at
+	var starts []int
+	spacing := 0
+	for r := range pitch {
+		if !occupied[r] {
+			continue
+		}
+		free := 0
+		for free < pitch && !occupied[(r-free-1+2*pitch)%pitch] {
+			free++
+		}
+		switch {
+		case free > spacing:
+			starts, spacing = []int{r}, free
+		case free == spacing && free > 0:
+			starts = append(starts, r)
+		}
+	}
+	if spacing == 0 {
+		// without spacing, frames may only start where no sprite continues from the line before
+		crossing := make([]bool, pitch)
+		for _, x := range opaque {
+			if x > 0 && !transparent[x-1] {
+				crossing[x%pitch] = true
+			}
+		}
+		for r := range pitch {
+			if !crossing[r] {
+				starts = append(starts, r)
+			}
+		}
+	}
+
+	first := opaque[0]
+	for _, r := range starts {
+		g := axisGrid{Offset: first - (first-r+pitch)%pitch, Size: pitch - spacing, Spacing: spacing}
+		if g.Offset < 0 {
+			continue
+		}
+		g.Frames = (len(transparent) - g.Offset + spacing) / pitch
+		filled := make([]bool, g.Frames)
+		fits := true
+		for _, x := range opaque {
+			frame := (x - g.Offset) / pitch
+			if frame >= g.Frames {
+				fits = false
+				break
+			}
+			filled[frame] = true
+		}
+		for _, f := range filled {
+			fits = fits && f
+		}
+		if fits {
+			return g, true
+		}
+	}
+	return axisGrid{}, false
+}
+
+// divideLines finds the division of the lines into the most frames, preferring wider spacing
+// between frames of the same number. Lines with gutters between the sprites have to be divided
+// into several frames.
+func divideLines(transparent []bool) (axisGrid, bool) {
+	var opaque []int
+	for i, t := range transparent {
+		if !t {
+			opaque = append(opaque, i)
+		}
+	}
+	if len(opaque) == 0 {
+		return axisGrid{}, false
+	}
+	var best axisGrid
+	found := false
+	for pitch := 1; pitch <= len(transparent); pitch++ {
+		g, ok := pitchGrid(transparent, opaque, pitch)
+		if ok && (!found || g.Frames > best.Frames || g.Frames == best.Frames && g.Spacing > best.Spacing) {
+			best, found = g, true
+		}
+	}
+	gutter := opaque[len(opaque)-1]-opaque[0]+1 > len(opaque)
+	return best, found && (best.Frames > 1 || !gutter)
+}
+
+// DetectGrid infers the grid of a sprite map whose frames are separated by fully transparent
+// gutters, returning options with the frame size, spacing, margin and offset set. The frames are
+// fitted as tightly around the sprites as the gutters allow. A sprite map without vertical gutters
+// is taken to be a single column, and one without horizontal gutters a single row.
+func DetectGrid(img image.Image) (Options, error) {
+	if IsEmpty(img) {
+		return Options{}, ErrNoSprites
+	}
+	b := img.Bounds()
+	x, okX := divideLines(transparentLines(img, false))
+	y, okY := divideLines(transparentLines(img, true))
+	if !okX || !okY {
+		return Options{}, ErrIrregularGutters
+	}
+
+	// Options have one spacing for both axes, so the frames of the axis with the wider gutters
+	// grow into them
+	axes := []*axisGrid{&x, &y}
+	spacing := -1
+	for _, g := range axes {
+		if g.Frames > 1 && (spacing < 0 || g.Spacing < spacing) {
+			spacing = g.Spacing
+		}
+	}
+	spacing = max(spacing, 0)
+	margin := min(x.Offset, y.Offset)
+	for i, g := range axes {
+		if g.Frames == 1 {
+			continue
+		}
+		g.Size += g.Spacing - spacing
+		g.Spacing = spacing
+		margin = min(margin, []int{b.Dx(), b.Dy()}[i]-g.end())
+	}
+	for i, g := range axes {
+		if g.Frames == 1 {
+			// a single frame takes all lines within the margin
+			g.Size = []int{b.Dx(), b.Dy()}[i] - g.Offset - margin
+		}
+	}
+	return Options{
+		FrameWidth:  x.Size,
+		FrameHeight: y.Size,
+		Spacing:     spacing,
+		Margin:      margin,
+		Offset:      image.Pt(x.Offset-margin, y.Offset-margin),
+	}, nil
+}
+
+// components returns the bounding boxes of the 8-connected groups of pixels of img that are not
+// fully transparent.
+func components(img image.Image) []image.Rectangle {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	visited := make([]bool, w*h)
+	opaque := func(x, y int) bool {
+		_, _, _, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+		return a != 0
+	}
+	var rects []image.Rectangle
+	var stack []image.Point
+	for y := range h {
+		for x := range w {
+			if visited[y*w+x] || !opaque(x, y) {
+				continue
+			}
+			visited[y*w+x] = true
+			rect := image.Rect(x, y, x+1, y+1)
+			stack = append(stack[:0], image.Pt(x, y))
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				rect = rect.Union(image.Rect(p.X, p.Y, p.X+1, p.Y+1))
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						n := image.Pt(p.X+dx, p.Y+dy)
+						if n.X < 0 || n.Y < 0 || n.X >= w || n.Y >= h || visited[n.Y*w+n.X] || !opaque(n.X, n.Y) {
+							continue
+						}
+						visited[n.Y*w+n.X] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+			rects = append(rects, rect.Add(b.Min))
+		}
+	}
+	return rects
+}
+
+// mergeOverlapping joins rectangles that overlap until all of them are disjoint, so that detached
+// parts reaching into the bounds of a sprite stay with it.
+func mergeOverlapping(rects []image.Rectangle) []image.Rectangle {
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(rects); i++ {
+			for j := i + 1; j < len(rects); j++ {
+				if rects[i].Overlaps(rects[j]) {
+					rects[i] = rects[i].Union(rects[j])
+					rects = append(rects[:j], rects[j+1:]...)
+					merged = true
+					j--
+				}
+			}
+		}
+	}
+	return rects
+}
+
+// DetectSprites finds the sprites of a sprite map without a regular grid as the bounding boxes of
+// connected pixels that are not fully transparent. Sprites whose vertical extents overlap form a
+// row, and the cells are returned in reading order.
+func DetectSprites(img image.Image) ([]Cell, error) {
+	rects := mergeOverlapping(components(img))
+	if len(rects) == 0 {
+		return nil, ErrNoSprites
+	}
+	sort.Slice(rects, func(i, j int) bool { return rects[i].Min.Y < rects[j].Min.Y })
+	var rows [][]image.Rectangle
+	bottom := 0
+	for i, r := range rects {
+		if i == 0 || r.Min.Y >= bottom {
+			rows = append(rows, nil)
+			bottom = r.Max.Y
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], r)
+		bottom = max(bottom, r.Max.Y)
+	}
+	var cells []Cell
+	for row, rowRects := range rows {
+		sort.Slice(rowRects, func(i, j int) bool { return rowRects[i].Min.X < rowRects[j].Min.X })
+		for column, r := range rowRects {
+			cells = append(cells, Cell{Rect: r, Row: row, Column: column})
+		}
+	}
+	return cells, nil
+}
+
+// uniformLines reports for every column of img, or every row if rows is set, the color all of its
+// pixels have, or nil if they differ.
+func uniformLines(img image.Image, rows bool) []color.Color {
+	b := img.Bounds()
+	outer, inner := b.Dx(), b.Dy()
+	if rows {
+		outer, inner = inner, outer
+	}
+	uniform := make([]color.Color, outer)
+	for i := range uniform {
+		at := func(j int) color.Color {
+			if rows {
+				return img.At(b.Min.X+j, b.Min.Y+i)
+			}
+			return img.At(b.Min.X+i, b.Min.Y+j)
+		}
+		uniform[i] = at(0)
+		for j := 1; j < inner && uniform[i] != nil; j++ {
+			if !sameColor(at(j), uniform[i]) {
+				uniform[i] = nil
+			}
+		}
+	}
+	return uniform
+}
+
+// sameColor reports whether a and b are the same color after converting them to alpha
+// premultiplied RGBA.
+func sameColor(a color.Color, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+// SeparatorColor guesses the color of the lines separating the frames of a sprite map as the most
+// frequent color of the rows and columns that have only one color, not counting fully transparent
+// ones.
+func SeparatorColor(img image.Image) (color.Color, error) {
+	counts := make(map[color.RGBA64]int)
+	var best color.RGBA64
+	for _, uniform := range append(uniformLines(img, false), uniformLines(img, true)...) {
+		if uniform == nil {
+			continue
+		}
+		c := color.RGBA64Model.Convert(uniform).(color.RGBA64)
+		if c.A == 0 {
+			continue
+		}
+		counts[c]++
+		if counts[c] > counts[best] {
+			best = c
+		}
+	}
+	if counts[best] == 0 {
+		return nil, ErrNoSeparators
+	}
+	return best, nil
+}
+
+// separatedSpans returns the runs of lines between the lines of the separator color.
+func separatedSpans(uniform []color.Color, separator color.Color) []span {
+	var spans []span
+	for i, c := range uniform {
+		switch {
+		case c != nil && sameColor(c, separator):
+		case len(spans) > 0 && spans[len(spans)-1].End == i:
+			spans[len(spans)-1].End++
+		default:
+			spans = append(spans, span{Start: i, End: i + 1})
+		}
+	}
+	return spans
+}
+
+// SeparatedCells returns the cells of a sprite map whose frames are divided by full rows and
+// columns of the separator color, in reading order. The separator lines do not belong to any cell.
+func SeparatedCells(img image.Image, separator color.Color) ([]Cell, error) {
+	b := img.Bounds()
+	columns := separatedSpans(uniformLines(img, false), separator)
+	rows := separatedSpans(uniformLines(img, true), separator)
+	if len(columns) == 0 || len(rows) == 0 {
+		return nil, errors.New("the sprite map consists of separator lines only")
+	}
+	if len(columns) == 1 && len(rows) == 1 && columns[0] == (span{End: b.Dx()}) && rows[0] == (span{End: b.Dy()}) {
+		return nil, ErrNoSeparators
+	}
+	var cells []Cell
+	for row, r := range rows {
+		for column, c := range columns {
+			cells = append(cells, Cell{Rect: image.Rect(b.Min.X+c.Start, b.Min.Y+r.Start, b.Min.X+c.End, b.Min.Y+r.End), Row: row, Column: column})
+		}
+	}
+	return cells, nil
+}
@@ -0,0 +1,206 @@
+package spritemapexplode
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+var (
+	opaqueRed = color.NRGBA{R: 255, A: 255}
+	magenta   = color.NRGBA{R: 255, B: 255, A: 255}
+)
+
+// testSheet returns a transparent image of the given size with the rectangles filled in red.
+func testSheet(width int, height int, rects ...image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for _, r := range rects {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.SetNRGBA(x, y, opaqueRed)
+			}
+		}
+	}
+	return img
+}
+
+func TestDetectGrid(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		opts Options
+	}{
+		{
+			name: "gutters between filled frames",
+			img:  testSheet(100, 32, image.Rect(0, 0, 32, 32), image.Rect(34, 0, 66, 32), image.Rect(68, 0, 100, 32)),
+			opts: Options{FrameWidth: 32, FrameHeight: 32, Spacing: 2},
+		},
+		{
+			name: "margin around the frames",
+			img:  testSheet(40, 14, image.Rect(3, 3, 19, 11), image.Rect(21, 3, 37, 11)),
+			opts: Options{FrameWidth: 16, FrameHeight: 8, Spacing: 2, Margin: 3},
+		},
+		{
+			name: "grid of sprites smaller than their cells",
+			img: testSheet(32, 32, image.Rect(2, 2, 14, 14), image.Rect(18, 2, 30, 14),
+				image.Rect(2, 18, 14, 30), image.Rect(18, 18, 30, 30)),
+			opts: Options{FrameWidth: 12, FrameHeight: 12, Spacing: 4, Margin: 2},
+		},
+		{
+			name: "different gutters of rows and columns",
+			img:  testSheet(20, 26, image.Rect(0, 0, 8, 8), image.Rect(10, 0, 18, 8), image.Rect(0, 14, 8, 22), image.Rect(10, 14, 18, 22)),
+			opts: Options{FrameWidth: 8, FrameHeight: 12, Spacing: 2},
+		},
+		{
+			name: "single sprite",
+			img:  testSheet(30, 30, image.Rect(5, 5, 25, 25)),
+			opts: Options{FrameWidth: 20, FrameHeight: 20, Margin: 5},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, detectErr := DetectGrid(test.img)
+			if detectErr != nil {
+				t.Fatal(detectErr)
+			}
+			if !reflect.DeepEqual(opts, test.opts) {
+				t.Errorf("got %+v, expected %+v", opts, test.opts)
+			}
+		})
+	}
+}
+
+func TestDetectGridErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		err  error
+	}{
+		{name: "transparent", img: testSheet(8, 8), err: ErrNoSprites},
+		{name: "irregular gutters", img: testSheet(10, 4, image.Rect(0, 0, 3, 4), image.Rect(4, 0, 10, 4)), err: ErrIrregularGutters},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if opts, detectErr := DetectGrid(test.img); detectErr != test.err {
+				t.Errorf("got %+v and %v, expected %v", opts, detectErr, test.err)
+			}
+		})
+	}
+}
+
+func TestDetectSprites(t *testing.T) {
+	tests := []struct {
+		name  string
+		img   image.Image
+		cells []Cell
+	}{
+		{
+			name: "rows in reading order",
+			img:  testSheet(20, 20, image.Rect(12, 1, 18, 6), image.Rect(1, 2, 5, 8), image.Rect(3, 12, 9, 19)),
+			cells: []Cell{
+				{Rect: image.Rect(1, 2, 5, 8), Row: 0, Column: 0},
+				{Rect: image.Rect(12, 1, 18, 6), Row: 0, Column: 1},
+				{Rect: image.Rect(3, 12, 9, 19), Row: 1, Column: 0},
+			},
+		},
+		{
+			name: "diagonal pixels belong together",
+			img:  testSheet(10, 10, image.Rect(1, 1, 3, 3), image.Rect(3, 3, 5, 5)),
+			cells: []Cell{
+				{Rect: image.Rect(1, 1, 5, 5), Row: 0, Column: 0},
+			},
+		},
+		{
+			name: "detached part within the bounds of a sprite",
+			img:  testSheet(10, 10, image.Rect(1, 1, 9, 2), image.Rect(1, 2, 2, 9), image.Rect(5, 5, 6, 6)),
+			cells: []Cell{
+				{Rect: image.Rect(1, 1, 9, 9), Row: 0, Column: 0},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cells, detectErr := DetectSprites(test.img)
+			if detectErr != nil {
+				t.Fatal(detectErr)
+			}
+			if !reflect.DeepEqual(cells, test.cells) {
+				t.Errorf("got %v, expected %v", cells, test.cells)
+			}
+		})
+	}
+	if _, detectErr := DetectSprites(testSheet(4, 4)); detectErr != ErrNoSprites {
+		t.Errorf("transparent sheet: got %v, expected %v", detectErr, ErrNoSprites)
+	}
+}
+
+// separatedSheet returns a sheet of checkered red cells divided by magenta lines at the given
+// columns and rows.
+func separatedSheet(width int, height int, columns []int, rows []int) *image.NRGBA {
+	img := testSheet(width, height)
+	for y := range height {
+		for x := y % 2; x < width; x += 2 {
+			img.SetNRGBA(x, y, opaqueRed)
+		}
+	}
+	for _, x := range columns {
+		for y := range height {
+			img.SetNRGBA(x, y, magenta)
+		}
+	}
+	for _, y := range rows {
+		for x := range width {
+			img.SetNRGBA(x, y, magenta)
+		}
+	}
+	return img
+}
+
+func TestSeparatedCells(t *testing.T) {
+	tests := []struct {
+		name  string
+		img   image.Image
+		cells []Cell
+	}{
+		{
+			name: "inner lines",
+			img:  separatedSheet(9, 5, []int{4}, nil),
+			cells: []Cell{
+				{Rect: image.Rect(0, 0, 4, 5), Row: 0, Column: 0},
+				{Rect: image.Rect(5, 0, 9, 5), Row: 0, Column: 1},
+			},
+		},
+		{
+			name: "border and uneven cells",
+			img:  separatedSheet(10, 9, []int{0, 3, 9}, []int{0, 4, 8}),
+			cells: []Cell{
+				{Rect: image.Rect(1, 1, 3, 4), Row: 0, Column: 0},
+				{Rect: image.Rect(4, 1, 9, 4), Row: 0, Column: 1},
+				{Rect: image.Rect(1, 5, 3, 8), Row: 1, Column: 0},
+				{Rect: image.Rect(4, 5, 9, 8), Row: 1, Column: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			separator, colorErr := SeparatorColor(test.img)
+			if colorErr != nil {
+				t.Fatal(colorErr)
+			}
+			if !sameColor(separator, magenta) {
+				t.Errorf("separator color is %v, expected %v", separator, magenta)
+			}
+			cells, cellsErr := SeparatedCells(test.img, separator)
+			if cellsErr != nil {
+				t.Fatal(cellsErr)
+			}
+			if !reflect.DeepEqual(cells, test.cells) {
+				t.Errorf("got %v, expected %v", cells, test.cells)
+			}
+		})
+	}
+	if _, cellsErr := SeparatedCells(testSheet(4, 4, image.Rect(0, 0, 4, 4)), magenta); cellsErr != ErrNoSeparators {
+		t.Errorf("sheet without separators: got %v, expected %v", cellsErr, ErrNoSeparators)
+	}
+}
@@ -0,0 +1,119 @@
+// Package spritemapexplode slices sprite maps into their frames. It is the core of the
+// spritemap-explode command, usable by other programs without writing any files.
+package spritemapexplode
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Options describe the grid of a sprite map and the frame variants to produce.
+type Options struct {
+	// FrameWidth and FrameHeight are the size of a frame. Alternatively, Columns and Rows give
+	// the number of frames per row and column, from which the frame size is derived.
+	FrameWidth  int
+	FrameHeight int
+	Columns     int
+	Rows        int
+
+	// RowHeights and ColumnWidths describe grids whose rows and columns differ in size. They take
+	// precedence over the uniform frame size.
+	RowHeights   []int
+	ColumnWidths []int
+
+	// Offset is the position of the grid relative to the top left corner of the sprite map, e.g.
+	// to skip a header strip.
+	Offset image.Point
+
+	// Margin is the number of pixels around the grid, and Spacing the number of pixels between
+	// neighboring cells.
+	Margin  int
+	Spacing int
+
+	// RowsBottomUp numbers and traverses the rows starting with the bottom one.
+	RowsBottomUp bool
+
+	// Frames is the number of frames in the sprite map if not 0. Missing columns or rows are
+	// derived from it, and frames beyond it are ignored.
+	Frames int
+
+	// KeepEmpty keeps the empty frames instead of leaving them out.
+	KeepEmpty bool
+
+	// EmptyAlpha and EmptyPixels loosen what counts as an empty frame: pixels of at most EmptyAlpha
+	// opacity count as transparent, and up to EmptyPixels other pixels are ignored, e.g. stray
+	// pixels left over by an eraser.
+	EmptyAlpha  uint8
+	EmptyPixels int
+
+	// Background is the color of the sheet behind the sprites if it has no transparency, e.g.
+	// magenta. Pixels of that color count as transparent when checking for empty frames.
+	Background color.Color
+
+	// MirrorLeft adds a copy of every frame flipped on the y axis, with the mirror tokens r for the
+	// original and l for the copy. MirrorDown adds a copy flipped on the x axis, with the tokens u
+	// and d, and MirrorUp does the same for frames facing down, with the tokens d and u. If the
+	// frames are mirrored on both axes, the tokens are joined, e.g. r-u.
+	MirrorLeft bool
+	MirrorDown bool
+	MirrorUp   bool
+
+	// Rotations adds a copy of every frame and mirror variant rotated clockwise by each of these
+	// angles, which are 90, 180 or 270, with the tokens rot90, rot180 and rot270.
+	Rotations []int
+
+	// NormalMap marks the sprite map as a normal map, whose mirrored copies get the red or green
+	// channel inverted, and copies rotated by 180 degrees both, so that they are lit correctly.
+	// Rotations by 90 and 270 degrees depend on the convention of the y axis and are not corrected.
+	NormalMap bool
+
+	// MirrorNames renames the mirror tokens r, l, u and d, and the rotation tokens.
+	MirrorNames map[string]string
+}
+
+// Frame is a frame of the sprite map.
+type Frame struct {
+	Row    int
+	Column int
+	// Mirror is the token of the mirror or rotation variant, or empty for the frame itself if no
+	// mirror variants have been requested.
+	Mirror string
+	// Rect is the source rectangle of the frame in the sprite map.
+	Rect  image.Rectangle
+	Image image.Image
+}
+
+// Explode slices img into the frames of the grid described by opts, in reading order. Empty
+// frames are left out unless KeepEmpty is set.
+func Explode(img image.Image, opts Options) ([]Frame, error) {
+	cells, gridErr := Grid(img.Bounds(), opts)
+	if gridErr != nil {
+		return nil, gridErr
+	}
+	var frames []Frame
+	for _, c := range cells {
+		subImage := SubImage(img, c.Rect)
+		if !opts.KeepEmpty && opts.FrameEmpty(subImage) {
+			continue
+		}
+		for _, v := range Variants(subImage, opts) {
+			frames = append(frames, Frame{Row: c.Row, Column: c.Column, Mirror: v.Mirror, Rect: c.Rect, Image: v.Image})
+		}
+	}
+	return frames, nil
+}
+
+// SubImage returns the part of img within r, sharing the pixels with img if its type supports
+// that.
+func SubImage(img image.Image, r image.Rectangle) image.Image {
+	if s, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	r = r.Intersect(img.Bounds())
+	sub := NewImage(img, r)
+	draw.Draw(sub, r, img, r.Min, draw.Src)
+	return sub
+}
@@ -0,0 +1,146 @@
+package spritemapexplode
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// frameSummary is what the tests compare of a frame: its position, variant and pixel at 0,0
+// relative to its bounds.
+type frameSummary struct {
+	Row    int
+	Column int
+	Mirror string
+	Rect   image.Rectangle
+	Corner color.NRGBA
+}
+
+func summarize(frames []Frame) []frameSummary {
+	summaries := make([]frameSummary, len(frames))
+	for i, f := range frames {
+		b := f.Image.Bounds()
+		summaries[i] = frameSummary{Row: f.Row, Column: f.Column, Mirror: f.Mirror, Rect: f.Rect,
+			Corner: color.NRGBAModel.Convert(f.Image.At(b.Min.X, b.Min.Y)).(color.NRGBA)}
+	}
+	return summaries
+}
+
+func TestExplode(t *testing.T) {
+	// two 4x4 frames, the left one with only its top left pixel set, the right one empty
+	sheet := testSheet(8, 4, image.Rect(0, 0, 1, 1))
+	tests := []struct {
+		name   string
+		opts   Options
+		frames []frameSummary
+	}{
+		{
+			name:   "empty frames are left out",
+			opts:   Options{FrameWidth: 4, FrameHeight: 4},
+			frames: []frameSummary{{Rect: image.Rect(0, 0, 4, 4), Corner: opaqueRed}},
+		},
+		{
+			name: "keep empty",
+			opts: Options{FrameWidth: 4, FrameHeight: 4, KeepEmpty: true},
+			frames: []frameSummary{
+				{Rect: image.Rect(0, 0, 4, 4), Corner: opaqueRed},
+				{Column: 1, Rect: image.Rect(4, 0, 8, 4)},
+			},
+		},
+		{
+			name: "mirrored",
+			opts: Options{FrameWidth: 4, FrameHeight: 4, MirrorLeft: true},
+			frames: []frameSummary{
+				{Mirror: "r", Rect: image.Rect(0, 0, 4, 4), Corner: opaqueRed},
+				{Mirror: "l", Rect: image.Rect(0, 0, 4, 4)},
+			},
+		},
+		{
+			name:   "stray pixels count as empty",
+			opts:   Options{FrameWidth: 4, FrameHeight: 4, EmptyPixels: 1},
+			frames: []frameSummary{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frames, explodeErr := Explode(sheet, test.opts)
+			if explodeErr != nil {
+				t.Fatal(explodeErr)
+			}
+			if summaries := summarize(frames); !reflect.DeepEqual(summaries, test.frames) {
+				t.Errorf("got %+v, expected %+v", summaries, test.frames)
+			}
+		})
+	}
+	if _, explodeErr := Explode(sheet, Options{}); explodeErr != ErrNoGrid {
+		t.Errorf("without grid: got %v, expected %v", explodeErr, ErrNoGrid)
+	}
+}
+
+func TestVariants(t *testing.T) {
+	// a 2x3 frame with only its top left pixel set
+	img := testSheet(2, 3, image.Rect(0, 0, 1, 1))
+	tests := []struct {
+		name    string
+		opts    Options
+		mirrors []string
+		// corners are the positions of the set pixel in every variant
+		corners []image.Point
+	}{
+		{name: "none", opts: Options{}, mirrors: []string{""}, corners: []image.Point{{0, 0}}},
+		{
+			name:    "left",
+			opts:    Options{MirrorLeft: true},
+			mirrors: []string{"r", "l"},
+			corners: []image.Point{{0, 0}, {1, 0}},
+		},
+		{
+			name:    "left and down",
+			opts:    Options{MirrorLeft: true, MirrorDown: true},
+			mirrors: []string{"r-u", "r-d", "l-u", "l-d"},
+			corners: []image.Point{{0, 0}, {0, 2}, {1, 0}, {1, 2}},
+		},
+		{
+			name:    "up",
+			opts:    Options{MirrorUp: true},
+			mirrors: []string{"d", "u"},
+			corners: []image.Point{{0, 0}, {0, 2}},
+		},
+		{
+			name:    "rotations",
+			opts:    Options{Rotations: []int{90, 180, 270}},
+			mirrors: []string{"", "rot90", "rot180", "rot270"},
+			corners: []image.Point{{0, 0}, {2, 0}, {1, 2}, {0, 1}},
+		},
+		{
+			name:    "renamed",
+			opts:    Options{MirrorLeft: true, MirrorNames: map[string]string{"r": "right", "l": "left"}},
+			mirrors: []string{"right", "left"},
+			corners: []image.Point{{0, 0}, {1, 0}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mirrors []string
+			var corners []image.Point
+			for _, v := range Variants(img, test.opts) {
+				mirrors = append(mirrors, v.Mirror)
+				b := v.Image.Bounds()
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					for x := b.Min.X; x < b.Max.X; x++ {
+						if _, _, _, a := v.Image.At(x, y).RGBA(); a != 0 {
+							corners = append(corners, image.Pt(x, y).Sub(b.Min))
+						}
+					}
+				}
+			}
+			if !reflect.DeepEqual(mirrors, test.mirrors) {
+				t.Errorf("got the variants %v, expected %v", mirrors, test.mirrors)
+			}
+			if !reflect.DeepEqual(corners, test.corners) {
+				t.Errorf("got the pixels at %v, expected %v", corners, test.corners)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package spritemapexplode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Cell is a region of the sprite map that holds a frame.
+type Cell struct {
+	Rect   image.Rectangle
+	Row    int
+	Column int
+}
+
+// ErrNoGrid is returned if the options neither give the frame size nor the number of frames per
+// row or column.
+var ErrNoGrid = errors.New("need the frame size or the number of columns and rows")
+
+// columnWidths returns the width of every column of a sprite map of the given width.
+func (o *Options) columnWidths(width int) ([]int, error) {
+	if len(o.ColumnWidths) != 0 {
+		return o.ColumnWidths, nil
+	}
+	return uniformSizes(width-o.Offset.X-2*o.Margin, o.FrameWidth, o.Columns, o.Spacing)
+}
+
+// rowHeights returns the height of every row of a sprite map of the given height.
+func (o *Options) rowHeights(height int) ([]int, error) {
+	if len(o.RowHeights) != 0 {
+		return o.RowHeights, nil
+	}
+	return uniformSizes(height-o.Offset.Y-2*o.Margin, o.FrameHeight, o.Rows, o.Spacing)
+}
+
+// uniformSizes divides total into parts of the given size, or into the given count of parts, with
+// spacing pixels between them. It fails if the parts would be empty.
+func uniformSizes(total int, size int, count int, spacing int) ([]int, error) {
+	switch {
+	case size != 0 && count == 0:
+		count = max((total+spacing)/(size+spacing), 0)
+	case size == 0 && count != 0:
+		size = (total - (count-1)*spacing) / count
+		if size <= 0 {
+			return nil, fmt.Errorf("%d frames do not fit into %d pixels", count, total)
+		}
+	case size == 0 && count == 0:
+		return nil, ErrNoGrid
+	}
+	sizes := make([]int, count)
+	for i := range sizes {
+		sizes[i] = size
+	}
+	return sizes, nil
+}
+
+// frameCountLayout returns a copy of the options without Frames, but with the missing columns or
+// rows derived from it, so that the grid holds that many frames.
+func (o Options) frameCountLayout(bounds image.Rectangle) Options {
+	n := o.Frames
+	o.Frames = 0
+	if o.Columns == 0 && o.FrameWidth == 0 && len(o.ColumnWidths) == 0 {
+		rows := 1
+		if heights, heightsErr := o.rowHeights(bounds.Dy()); heightsErr == nil {
+			rows = max(len(heights), 1)
+		}
+		o.Columns = (n + rows - 1) / rows
+	}
+	if o.Rows == 0 && o.FrameHeight == 0 && len(o.RowHeights) == 0 {
+		columns := 1
+		if widths, widthsErr := o.columnWidths(bounds.Dx()); widthsErr == nil {
+			columns = max(len(widths), 1)
+		}
+		o.Rows = (n + columns - 1) / columns
+	}
+	return o
+}
+
+// Grid returns the cells of a sprite map with the given bounds in reading order.
+func Grid(bounds image.Rectangle, opts Options) ([]Cell, error) {
+	if opts.Frames != 0 {
+		cells, gridErr := Grid(bounds, opts.frameCountLayout(bounds))
+		if gridErr != nil {
+			return nil, gridErr
+		}
+		return cells[:min(len(cells), opts.Frames)], nil
+	}
+
+	rowHeights, heightsErr := opts.rowHeights(bounds.Dy())
+	if heightsErr != nil {
+		return nil, heightsErr
+	}
+	columnWidths, widthsErr := opts.columnWidths(bounds.Dx())
+	if widthsErr != nil {
+		return nil, widthsErr
+	}
+
+	rowTops := make([]int, len(rowHeights))
+	y := bounds.Min.Y + opts.Offset.Y + opts.Margin
+	for i, height := range rowHeights {
+		rowTops[i] = y
+		y += height + opts.Spacing
+	}
+
+	cells := make([]Cell, 0, len(rowHeights)*len(columnWidths))
+	for row := range rowHeights {
+		i := row
+		if opts.RowsBottomUp {
+			i = len(rowHeights) - 1 - row
+		}
+		x := bounds.Min.X + opts.Offset.X + opts.Margin
+		for column, width := range columnWidths {
+			cells = append(cells, Cell{Rect: image.Rect(x, rowTops[i], x+width, rowTops[i]+rowHeights[i]), Row: row, Column: column})
+			x += width + opts.Spacing
+		}
+	}
+	return cells, nil
+}
@@ -0,0 +1,114 @@
+package spritemapexplode
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestGrid(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds image.Rectangle
+		opts   Options
+		cells  []Cell
+	}{
+		{
+			name:   "frame size",
+			bounds: image.Rect(0, 0, 32, 16),
+			opts:   Options{FrameWidth: 16, FrameHeight: 16},
+			cells: []Cell{
+				{Rect: image.Rect(0, 0, 16, 16), Row: 0, Column: 0},
+				{Rect: image.Rect(16, 0, 32, 16), Row: 0, Column: 1},
+			},
+		},
+		{
+			name:   "columns and rows",
+			bounds: image.Rect(0, 0, 20, 20),
+			opts:   Options{Columns: 2, Rows: 2},
+			cells: []Cell{
+				{Rect: image.Rect(0, 0, 10, 10), Row: 0, Column: 0},
+				{Rect: image.Rect(10, 0, 20, 10), Row: 0, Column: 1},
+				{Rect: image.Rect(0, 10, 10, 20), Row: 1, Column: 0},
+				{Rect: image.Rect(10, 10, 20, 20), Row: 1, Column: 1},
+			},
+		},
+		{
+			name:   "margin, spacing and offset",
+			bounds: image.Rect(0, 0, 28, 14),
+			opts:   Options{FrameWidth: 8, FrameHeight: 8, Margin: 2, Spacing: 2, Offset: image.Pt(3, 1)},
+			cells: []Cell{
+				{Rect: image.Rect(5, 3, 13, 11), Row: 0, Column: 0},
+				{Rect: image.Rect(15, 3, 23, 11), Row: 0, Column: 1},
+			},
+		},
+		{
+			name:   "bounds not at the origin",
+			bounds: image.Rect(10, 20, 26, 28),
+			opts:   Options{FrameWidth: 8, FrameHeight: 8},
+			cells: []Cell{
+				{Rect: image.Rect(10, 20, 18, 28), Row: 0, Column: 0},
+				{Rect: image.Rect(18, 20, 26, 28), Row: 0, Column: 1},
+			},
+		},
+		{
+			name:   "column widths and row heights",
+			bounds: image.Rect(0, 0, 30, 30),
+			opts:   Options{ColumnWidths: []int{10, 20}, RowHeights: []int{30}},
+			cells: []Cell{
+				{Rect: image.Rect(0, 0, 10, 30), Row: 0, Column: 0},
+				{Rect: image.Rect(10, 0, 30, 30), Row: 0, Column: 1},
+			},
+		},
+		{
+			name:   "rows bottom up",
+			bounds: image.Rect(0, 0, 8, 16),
+			opts:   Options{FrameWidth: 8, FrameHeight: 8, RowsBottomUp: true},
+			cells: []Cell{
+				{Rect: image.Rect(0, 8, 8, 16), Row: 0, Column: 0},
+				{Rect: image.Rect(0, 0, 8, 8), Row: 1, Column: 0},
+			},
+		},
+		{
+			name:   "frame count",
+			bounds: image.Rect(0, 0, 24, 16),
+			opts:   Options{FrameWidth: 8, FrameHeight: 8, Frames: 4},
+			cells: []Cell{
+				{Rect: image.Rect(0, 0, 8, 8), Row: 0, Column: 0},
+				{Rect: image.Rect(8, 0, 16, 8), Row: 0, Column: 1},
+				{Rect: image.Rect(16, 0, 24, 8), Row: 0, Column: 2},
+				{Rect: image.Rect(0, 8, 8, 16), Row: 1, Column: 0},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cells, gridErr := Grid(test.bounds, test.opts)
+			if gridErr != nil {
+				t.Fatal(gridErr)
+			}
+			if !reflect.DeepEqual(cells, test.cells) {
+				t.Errorf("got %v, expected %v", cells, test.cells)
+			}
+		})
+	}
+}
+
+func TestGridErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds image.Rectangle
+		opts   Options
+	}{
+		{name: "no size", bounds: image.Rect(0, 0, 16, 16), opts: Options{}},
+		{name: "too many columns", bounds: image.Rect(0, 0, 16, 16), opts: Options{Columns: 17, Rows: 1}},
+		{name: "too many rows with spacing", bounds: image.Rect(0, 0, 16, 16), opts: Options{Columns: 1, Rows: 5, Spacing: 4}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cells, gridErr := Grid(test.bounds, test.opts); gridErr == nil {
+				t.Errorf("got %v, expected an error", cells)
+			}
+		})
+	}
+}
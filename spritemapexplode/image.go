@@ -0,0 +1,200 @@
+package spritemapexplode
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+)
+
+// IsEmpty reports whether all pixels of img are fully transparent.
+func IsEmpty(img image.Image) bool {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// FrameEmpty reports whether img counts as an empty frame, which it does if it is fully
+// transparent or of the Background color, or within the limits of EmptyAlpha and EmptyPixels.
+func (o Options) FrameEmpty(img image.Image) bool {
+	b := img.Bounds()
+	visible := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.At(x, y)
+			if _, _, _, a := c.RGBA(); a>>8 > uint32(o.EmptyAlpha) && (o.Background == nil || !sameColor(c, o.Background)) {
+				if visible++; visible > o.EmptyPixels {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Is16Bit reports whether the color model of img has 16 bits per channel.
+func Is16Bit(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model:
+		return true
+	}
+	return false
+}
+
+// NewImage returns a transparent image of the bounds r to hold pixels of img. It has the palette
+// of img if img is paletted and the palette has a transparent color, and otherwise 16 bits per
+// channel if img has them and 8 bits if not.
+func NewImage(img image.Image, r image.Rectangle) draw.Image {
+	if p, ok := img.(*image.Paletted); ok {
+		for i, c := range p.Palette {
+			if _, _, _, a := c.RGBA(); a == 0 {
+				paletted := image.NewPaletted(r, p.Palette)
+				for j := range paletted.Pix {
+					paletted.Pix[j] = uint8(i)
+				}
+				return paletted
+			}
+		}
+	}
+	if Is16Bit(img) {
+		return image.NewNRGBA64(r)
+	}
+	return image.NewNRGBA(r)
+}
+
+// MirrorY returns a copy of img flipped on the y axis, i.e. facing left if it has been facing
+// right before.
+func MirrorY(img image.Image) image.Image {
+	mirrorImg := NewImage(img, img.Bounds())
+	mx := img.Bounds().Max.X
+	for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+		mx--
+		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+			mirrorImg.Set(mx, y, img.At(x, y))
+		}
+	}
+	return mirrorImg
+}
+
+// MirrorX returns a copy of img flipped on the x axis, i.e. facing down if it has been facing up
+// before.
+func MirrorX(img image.Image) image.Image {
+	mirrorImg := NewImage(img, img.Bounds())
+	my := img.Bounds().Max.Y
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		my--
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			mirrorImg.Set(x, my, img.At(x, y))
+		}
+	}
+	return mirrorImg
+}
+
+// FlipNormals returns a copy of a normal map image with the x component of the normals in the
+// red channel inverted if x is set, and the y component in the green channel if y is set, as
+// needed after mirroring it on the y or x axis.
+func FlipNormals(img image.Image, x bool, y bool) image.Image {
+	b := img.Bounds()
+	flipped := NewImage(img, b)
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		for px := b.Min.X; px < b.Max.X; px++ {
+			c := color.NRGBA64Model.Convert(img.At(px, py)).(color.NRGBA64)
+			if c.A != 0 && x {
+				c.R = 0xffff - c.R
+			}
+			if c.A != 0 && y {
+				c.G = 0xffff - c.G
+			}
+			flipped.Set(px, py, c)
+		}
+	}
+	return flipped
+}
+
+// Rotate returns a copy of img rotated clockwise by 90, 180 or 270 degrees. Other angles return
+// img itself.
+func Rotate(img image.Image, degrees int) image.Image {
+	b := img.Bounds()
+	switch degrees {
+	case 90:
+		rotated := NewImage(img, image.Rectangle{Min: b.Min, Max: b.Min.Add(image.Pt(b.Dy(), b.Dx()))})
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				rotated.Set(b.Min.X+b.Dy()-1-y, b.Min.Y+x, img.At(b.Min.X+x, b.Min.Y+y))
+			}
+		}
+		return rotated
+	case 180:
+		return MirrorX(MirrorY(img))
+	case 270:
+		return Rotate(Rotate(img, 90), 180)
+	}
+	return img
+}
+
+// Variant is a frame image together with the token of its mirror or rotation variant.
+type Variant struct {
+	Mirror string
+	Image  image.Image
+}
+
+// Variants returns the frame image and its mirrored and rotated copies requested by opts.
+func Variants(img image.Image, opts Options) []Variant {
+	token := func(mirror string) string {
+		if name, ok := opts.MirrorNames[mirror]; ok {
+			return name
+		}
+		return mirror
+	}
+	mirrorY, mirrorX, rotate := MirrorY, MirrorX, Rotate
+	if opts.NormalMap {
+		mirrorY = func(img image.Image) image.Image { return FlipNormals(MirrorY(img), true, false) }
+		mirrorX = func(img image.Image) image.Image { return FlipNormals(MirrorX(img), false, true) }
+		rotate = func(img image.Image, degrees int) image.Image {
+			return FlipNormals(Rotate(img, degrees), degrees == 180, degrees == 180)
+		}
+	}
+	variants := []Variant{{Image: img}}
+	if opts.MirrorLeft {
+		variants = []Variant{
+			{Mirror: token("r"), Image: img},
+			{Mirror: token("l"), Image: mirrorY(img)},
+		}
+	}
+	if opts.MirrorDown || opts.MirrorUp {
+		original, copied := token("u"), token("d")
+		if opts.MirrorUp {
+			original, copied = copied, original
+		}
+		var flipped []Variant
+		for _, v := range variants {
+			facing, flip := original, copied
+			if v.Mirror != "" {
+				facing, flip = v.Mirror+"-"+facing, v.Mirror+"-"+flip
+			}
+			flipped = append(flipped, Variant{Mirror: facing, Image: v.Image}, Variant{Mirror: flip, Image: mirrorX(v.Image)})
+		}
+		variants = flipped
+	}
+	if len(opts.Rotations) != 0 {
+		var rotated []Variant
+		for _, v := range variants {
+			rotated = append(rotated, v)
+			for _, degrees := range opts.Rotations {
+				rotation := token("rot" + strconv.Itoa(degrees))
+				if v.Mirror != "" {
+					rotation = v.Mirror + "-" + rotation
+				}
+				rotated = append(rotated, Variant{Mirror: rotation, Image: rotate(v.Image, degrees)})
+			}
+		}
+		variants = rotated
+	}
+	return variants
+}
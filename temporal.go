@@ -0,0 +1,51 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"image/gif"
+	"os"
+)
+
+// loadTemporalGIF decodes the animated GIF filename and lays out its coalesced frames side by side
+// in a single row sprite map, one cell per frame with the frame delay as duration.
+func loadTemporalGIF(filename string) (SpriteMap, []cell, error) {
+	file, openErr := os.Open(filename)
+	if openErr != nil {
+		return nil, nil, openErr
+	}
+	defer file.Close()
+	anim, decodeErr := gif.DecodeAll(file)
+	if decodeErr != nil {
+		return nil, nil, decodeErr
+	}
+
+	width, height := anim.Config.Width, anim.Config.Height
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	sheet := image.NewNRGBA(image.Rect(0, 0, width*len(anim.Image), height))
+	var cells []cell
+	for i, frame := range anim.Image {
+		var disposal byte
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		rect := image.Rect(i*width, 0, (i+1)*width, height)
+		draw.Draw(sheet, rect, canvas, image.Point{}, draw.Src)
+		cells = append(cells, cell{Rect: rect, Column: i, Duration: anim.Delay[i] * 10})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return sheet, cells, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+type texturePackerRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type texturePackerSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type texturePackerFrame struct {
+	Frame            texturePackerRect `json:"frame"`
+	Rotated          bool              `json:"rotated"`
+	Trimmed          bool              `json:"trimmed"`
+	SpriteSourceSize texturePackerRect `json:"spriteSourceSize"`
+	SourceSize       texturePackerSize `json:"sourceSize"`
+	Pivot            struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+	} `json:"pivot"`
+}
+
+type texturePackerMeta struct {
+	App     string            `json:"app"`
+	Version string            `json:"version"`
+	Image   string            `json:"image"`
+	Format  string            `json:"format"`
+	Size    texturePackerSize `json:"size"`
+	Scale   string            `json:"scale"`
+}
+
+// texturePackerAtlas is the TexturePacker JSON hash format.
+type texturePackerAtlas struct {
+	Frames map[string]texturePackerFrame `json:"frames"`
+	Meta   texturePackerMeta             `json:"meta"`
+}
+
+// newTexturePackerAtlas returns the regions as unrotated frames, trimmed if -trim has trimmed
+// them, pivoting around the anchor of e.Pivot within the untrimmed frame. The sprite map is
+// referenced relative to filename.
+func newTexturePackerAtlas(filename string, e *sheetExport) texturePackerAtlas {
+	atlas := texturePackerAtlas{
+		Frames: make(map[string]texturePackerFrame, len(e.Regions)),
+		Meta: texturePackerMeta{
+			App:     "https://github.com/hschendel/spritemap-explode",
+			Version: "1.0",
+			Image:   e.relImage(filename),
+			Format:  "RGBA8888",
+			Size:    texturePackerSize{W: e.Size.X, H: e.Size.Y},
+			Scale:   "1",
+		},
+	}
+	pivot, ok := anchors[e.Pivot]
+	if !ok {
+		pivot = anchors["center"]
+	}
+	for _, r := range e.Regions {
+		frame, visible := r.frame(), r.frame().Sub(r.Rect.Min)
+		f := texturePackerFrame{
+			Frame:            texturePackerRect{X: frame.Min.X, Y: frame.Min.Y, W: frame.Dx(), H: frame.Dy()},
+			Trimmed:          r.trimmed(),
+			SpriteSourceSize: texturePackerRect{X: visible.Min.X, Y: visible.Min.Y, W: visible.Dx(), H: visible.Dy()},
+			SourceSize:       texturePackerSize{W: r.Rect.Dx(), H: r.Rect.Dy()},
+		}
+		f.Pivot.X, f.Pivot.Y = float64(pivot.X)/2, float64(pivot.Y)/2
+		atlas.Frames[r.Name] = f
+	}
+	return atlas
+}
+
+// writeTexturePacker writes a TexturePacker JSON hash to <base>-texturepacker.json, or to the
+// Data file of the export.
+func writeTexturePacker(e *sheetExport) error {
+	filename := e.Base + "-texturepacker.json"
+	if e.Data != "" {
+		filename = e.Data
+	}
+	data, marshalErr := json.MarshalIndent(newTexturePackerAtlas(filename, e), "", "  ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return writeFile(filename, append(data, '\n'), 0644)
+}
+
+// phaserFrame is a frame of the JSON array format, which keeps the order of the frames.
+type phaserFrame struct {
+	Filename string `json:"filename"`
+	texturePackerFrame
+}
+
+// phaserAtlas is the JSON array format loaded by Phaser 3's atlas loader.
+type phaserAtlas struct {
+	Frames []phaserFrame     `json:"frames"`
+	Meta   texturePackerMeta `json:"meta"`
+}
+
+// writePhaser3 writes the regions in the JSON array format of Phaser 3 atlases to
+// <base>-phaser3.json, in the order the frames were written.
+func writePhaser3(e *sheetExport) error {
+	filename := e.Base + "-phaser3.json"
+	hash := newTexturePackerAtlas(filename, e)
+	atlas := phaserAtlas{Frames: make([]phaserFrame, len(e.Regions)), Meta: hash.Meta}
+	for i, r := range e.Regions {
+		atlas.Frames[i] = phaserFrame{Filename: r.Name, texturePackerFrame: hash.Frames[r.Name]}
+	}
+	data, marshalErr := json.MarshalIndent(atlas, "", "  ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return writeFile(filename, append(data, '\n'), 0644)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// decodeImage decodes an image like image.Decode, but also reads Truevision TGA files, which are
+// recognized by their file extension because they have no magic number.
+func decodeImage(r io.Reader, filename string) (image.Image, string, error) {
+	if strings.EqualFold(filepath.Ext(filename), ".tga") {
+		img, decodeErr := decodeTGA(r)
+		return img, "tga", decodeErr
+	}
+	return image.Decode(r)
+}
+
+// tgaHeader is the fixed size header of a TGA file.
+type tgaHeader struct {
+	IDLength       uint8
+	ColorMapType   uint8
+	ImageType      uint8
+	ColorMapFirst  uint16
+	ColorMapLength uint16
+	ColorMapDepth  uint8
+	XOrigin        uint16
+	YOrigin        uint16
+	Width          uint16
+	Height         uint16
+	Depth          uint8
+	Descriptor     uint8
+}
+
+// TGA image types, with 8 added for run length encoding.
+const (
+	tgaColorMapped = 1
+	tgaTrueColor   = 2
+	tgaGrayscale   = 3
+	tgaRLE         = 8
+)
+
+// tgaColor converts one little endian pixel of the given depth in bits to a color. Pixels of
+// grayscale images are a gray value optionally followed by alpha.
+func tgaColor(pixel []byte, depth uint8, grayscale bool) color.NRGBA {
+	switch {
+	case grayscale && depth == 8:
+		return color.NRGBA{R: pixel[0], G: pixel[0], B: pixel[0], A: 255}
+	case grayscale:
+		return color.NRGBA{R: pixel[0], G: pixel[0], B: pixel[0], A: pixel[1]}
+	case depth == 15 || depth == 16:
+		v := binary.LittleEndian.Uint16(pixel)
+		scale := func(c uint16) uint8 { return uint8(c<<3 | c>>2) }
+		c := color.NRGBA{R: scale(v >> 10 & 0x1f), G: scale(v >> 5 & 0x1f), B: scale(v & 0x1f), A: 255}
+		if depth == 16 && v&0x8000 == 0 {
+			c.A = 0
+		}
+		return c
+	case depth == 24:
+		return color.NRGBA{R: pixel[2], G: pixel[1], B: pixel[0], A: 255}
+	default:
+		return color.NRGBA{R: pixel[2], G: pixel[1], B: pixel[0], A: pixel[3]}
+	}
+}
+
+// decodeTGA decodes an uncompressed or run length encoded color mapped, true color or grayscale
+// TGA image.
+func decodeTGA(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	var h tgaHeader
+	if readErr := binary.Read(br, binary.LittleEndian, &h); readErr != nil {
+		return nil, readErr
+	}
+	kind := h.ImageType &^ tgaRLE
+	switch {
+	case kind == tgaColorMapped && h.ColorMapType == 1 && h.Depth == 8:
+	case kind == tgaTrueColor && (h.Depth == 15 || h.Depth == 16 || h.Depth == 24 || h.Depth == 32):
+	case kind == tgaGrayscale && (h.Depth == 8 || h.Depth == 16):
+	default:
+		return nil, fmt.Errorf("unsupported TGA image type %d with %d bits per pixel", h.ImageType, h.Depth)
+	}
+	if _, discardErr := br.Discard(int(h.IDLength)); discardErr != nil {
+		return nil, discardErr
+	}
+
+	var colorMap []color.NRGBA
+	if h.ColorMapType == 1 {
+		switch h.ColorMapDepth {
+		case 15, 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("unsupported TGA color map with %d bits per entry", h.ColorMapDepth)
+		}
+		entry := make([]byte, (h.ColorMapDepth+7)/8)
+		for range h.ColorMapLength {
+			if _, readErr := io.ReadFull(br, entry); readErr != nil {
+				return nil, readErr
+			}
+			colorMap = append(colorMap, tgaColor(entry, h.ColorMapDepth, false))
+		}
+	}
+
+	// The pixel data grows as it is read instead of being allocated for the size in the header,
+	// so that a truncated or forged file fails before claiming gigabytes.
+	width, height := int(h.Width), int(h.Height)
+	pixelSize := int(h.Depth+7) / 8
+	size := width * height * pixelSize
+	var data []byte
+	if h.ImageType&tgaRLE == 0 {
+		var readErr error
+		if data, readErr = io.ReadAll(io.LimitReader(br, int64(size))); readErr != nil {
+			return nil, readErr
+		}
+		if len(data) < size {
+			return nil, io.ErrUnexpectedEOF
+		}
+	} else {
+		for len(data) < size {
+			packet, readErr := br.ReadByte()
+			if readErr != nil {
+				return nil, readErr
+			}
+			count := int(packet&0x7f) + 1
+			if len(data)+count*pixelSize > size {
+				return nil, fmt.Errorf("TGA run exceeds the image")
+			}
+			start := len(data)
+			if packet&0x80 == 0 {
+				data = append(data, make([]byte, count*pixelSize)...)
+				if _, readErr := io.ReadFull(br, data[start:]); readErr != nil {
+					return nil, readErr
+				}
+			} else {
+				data = append(data, make([]byte, pixelSize)...)
+				if _, readErr := io.ReadFull(br, data[start:]); readErr != nil {
+					return nil, readErr
+				}
+				for j := 1; j < count; j++ {
+					data = append(data, data[start:start+pixelSize]...)
+				}
+			}
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	rightToLeft, topToBottom := h.Descriptor&0x10 != 0, h.Descriptor&0x20 != 0
+	invisible := true
+	for i := 0; i < width*height; i++ {
+		pixel := data[i*pixelSize : (i+1)*pixelSize]
+		var c color.NRGBA
+		if kind == tgaColorMapped {
+			index := int(pixel[0]) - int(h.ColorMapFirst)
+			if index < 0 || index >= len(colorMap) {
+				return nil, fmt.Errorf("TGA color index %d outside of the color map", pixel[0])
+			}
+			c = colorMap[index]
+		} else {
+			c = tgaColor(pixel, h.Depth, kind == tgaGrayscale)
+		}
+		x, y := i%width, i/width
+		if rightToLeft {
+			x = width - 1 - x
+		}
+		if !topToBottom {
+			y = height - 1 - y
+		}
+		img.SetNRGBA(x, y, c)
+		invisible = invisible && c.A == 0
+	}
+	// Many writers leave the alpha channel of 16 and 32 bit images empty instead of declaring
+	// that there is none, so an image without any visible pixel is taken as fully opaque.
+	if invisible && h.Descriptor&0x0f == 0 {
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 255
+		}
+	}
+	return img, nil
+}
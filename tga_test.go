@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// tgaFile returns a TGA file with the header and the data following it.
+func tgaFile(h tgaHeader, data ...byte) []byte {
+	var file bytes.Buffer
+	binary.Write(&file, binary.LittleEndian, h)
+	file.Write(data)
+	return file.Bytes()
+}
+
+func TestDecodeTGA(t *testing.T) {
+	red, green, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{G: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+	half := color.NRGBA{R: 255, A: 128}
+	tests := []struct {
+		name   string
+		file   []byte
+		pixels [][]color.NRGBA
+	}{
+		{
+			name: "true color from the bottom left",
+			file: tgaFile(tgaHeader{ImageType: tgaTrueColor, Width: 2, Height: 2, Depth: 24},
+				0, 0, 255, 0, 255, 0,
+				255, 0, 0, 0, 0, 255),
+			pixels: [][]color.NRGBA{{blue, red}, {red, green}},
+		},
+		{
+			name: "true color from the top right",
+			file: tgaFile(tgaHeader{ImageType: tgaTrueColor, Width: 2, Height: 1, Depth: 32, Descriptor: 0x38},
+				0, 0, 255, 128, 255, 0, 0, 255),
+			pixels: [][]color.NRGBA{{blue, half}},
+		},
+		{
+			name: "run length encoded",
+			file: tgaFile(tgaHeader{ImageType: tgaTrueColor | tgaRLE, Width: 3, Height: 2, Depth: 24, Descriptor: 0x20},
+				0x82, 0, 0, 255,
+				0x01, 0, 255, 0, 255, 0, 0,
+				0x80, 0, 255, 0),
+			pixels: [][]color.NRGBA{{red, red, red}, {green, blue, green}},
+		},
+		{
+			name: "color mapped",
+			file: tgaFile(tgaHeader{ColorMapType: 1, ImageType: tgaColorMapped, ColorMapFirst: 1, ColorMapLength: 2, ColorMapDepth: 24,
+				Width: 2, Height: 1, Depth: 8, Descriptor: 0x20},
+				0, 0, 255, 255, 0, 0,
+				2, 1),
+			pixels: [][]color.NRGBA{{blue, red}},
+		},
+		{
+			name: "empty alpha channel",
+			file: tgaFile(tgaHeader{ImageType: tgaTrueColor, Width: 1, Height: 1, Depth: 32},
+				0, 255, 0, 0),
+			pixels: [][]color.NRGBA{{green}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img, decodeErr := decodeTGA(bytes.NewReader(test.file))
+			if decodeErr != nil {
+				t.Fatal(decodeErr)
+			}
+			if size := img.Bounds().Size(); size != image.Pt(len(test.pixels[0]), len(test.pixels)) {
+				t.Fatalf("size is %v", size)
+			}
+			for y, row := range test.pixels {
+				for x, expected := range row {
+					if c := color.NRGBAModel.Convert(img.At(x, y)); c != expected {
+						t.Errorf("pixel %d,%d is %v, expected %v", x, y, c, expected)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeTGAErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{name: "truncated header", file: tgaFile(tgaHeader{ImageType: tgaTrueColor, Depth: 24})[:10]},
+		{name: "truncated pixels", file: tgaFile(tgaHeader{ImageType: tgaTrueColor, Width: 2, Height: 1, Depth: 24}, 1, 2, 3, 4)},
+		{name: "truncated run", file: tgaFile(tgaHeader{ImageType: tgaTrueColor | tgaRLE, Width: 2, Height: 1, Depth: 24}, 0x01, 1, 2, 3)},
+		{name: "run beyond the image", file: tgaFile(tgaHeader{ImageType: tgaTrueColor | tgaRLE, Width: 2, Height: 1, Depth: 24}, 0x82, 1, 2, 3)},
+		{name: "huge image without data", file: tgaFile(tgaHeader{ImageType: tgaTrueColor, Width: 65535, Height: 65535, Depth: 32})},
+		{name: "color index outside of the map", file: tgaFile(tgaHeader{ColorMapType: 1, ImageType: tgaColorMapped, ColorMapLength: 1,
+			ColorMapDepth: 24, Width: 1, Height: 1, Depth: 8}, 0, 0, 0, 1)},
+		{name: "unsupported color map depth", file: tgaFile(tgaHeader{ColorMapType: 1, ImageType: tgaColorMapped, ColorMapLength: 1,
+			ColorMapDepth: 8, Width: 1, Height: 1, Depth: 8}, 0, 0)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, decodeErr := decodeTGA(bytes.NewReader(test.file)); decodeErr == nil {
+				t.Error("decoding does not fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"path"
+	"sort"
+	"strings"
+)
+
+const tileSize = 8
+
+// tile holds the palette indices of the pixels of one tile, indexed by y and x.
+type tile [tileSize][tileSize]uint8
+
+func (t tile) flipH() tile {
+	var flipped tile
+	for y := range t {
+		for x := range t[y] {
+			flipped[y][tileSize-1-x] = t[y][x]
+		}
+	}
+	return flipped
+}
+
+func (t tile) flipV() tile {
+	var flipped tile
+	for y := range t {
+		flipped[tileSize-1-y] = t[y]
+	}
+	return flipped
+}
+
+// tileFormat describes the tile data of a retro target. Tiles splits a frame into indexed tiles
+// and for targets with per sprite palettes also returns the palette data. Serialize encodes a
+// single tile. MapEntry encodes a tile map entry referencing a tile, possibly flipped; it is nil
+// for targets whose tile maps cannot express flipping. IndexBits is the width of the tile index
+// within a MapEntry. If AsmInclude is set, an assembly include file with the tile index of every
+// frame is written as well. Palette is set for targets whose Tiles returns palette data, which is
+// written into the .pal file of every frame.
+type tileFormat struct {
+	Extension  string
+	TileHeight int
+	Tiles      func(img image.Image) (tiles []tile, palette []byte, err error)
+	Serialize  func(t tile) []byte
+	MapEntry   func(index int, flipH, flipV bool) []byte
+	IndexBits  int
+	AsmInclude bool
+	Palette    bool
+}
+
+// indexBits returns the number of bits of a tile index in the tile maps of the target.
+func (f tileFormat) indexBits() int {
+	if f.MapEntry == nil {
+		return 8
+	}
+	return f.IndexBits
+}
+
+var tileFormats = map[string]tileFormat{
+	"nes":            {Extension: ".chr", TileHeight: 8, Tiles: nesTiles, Serialize: serializePlanar2bpp},
+	"gb":             {Extension: ".2bpp", TileHeight: 8, Tiles: gbTiles(frameTiles), Serialize: serializeInterleaved2bpp, AsmInclude: true},
+	"gb-8x16":        {Extension: ".2bpp", TileHeight: 16, Tiles: gbTiles(frameTiles8x16), Serialize: serializeInterleaved2bpp, AsmInclude: true},
+	"gba":            {Extension: ".4bpp", TileHeight: 8, Tiles: paletteTiles(frameTiles, bgr555, binary.LittleEndian), Serialize: serializeLinear4bpp(false), MapEntry: mapEntry16(binary.LittleEndian, 10, 11), IndexBits: 10, Palette: true},
+	"snes":           {Extension: ".4bpp", TileHeight: 8, Tiles: paletteTiles(frameTiles, bgr555, binary.LittleEndian), Serialize: serializePlanar4bpp, MapEntry: mapEntry16(binary.LittleEndian, 14, 15), IndexBits: 10, Palette: true},
+	"genesis":        {Extension: ".4bpp", TileHeight: 8, Tiles: paletteTiles(frameTiles, genesisCRAM, binary.BigEndian), Serialize: serializeLinear4bpp(true), MapEntry: mapEntry16(binary.BigEndian, 11, 12), IndexBits: 11, Palette: true},
+	"genesis-sprite": {Extension: ".4bpp", TileHeight: 8, Tiles: paletteTiles(frameTilesGenesisSprite, genesisCRAM, binary.BigEndian), Serialize: serializeLinear4bpp(true), MapEntry: mapEntry16(binary.BigEndian, 11, 12), IndexBits: 11, Palette: true},
+}
+
+func tileFormatNames() string {
+	names := make([]string, 0, len(tileFormats))
+	for name := range tileFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// pixelAt returns the color at x, y, or transparent if that is outside of the image. Frames
+// are padded like that to a multiple of the tile size.
+func pixelAt(img image.Image, x, y int) color.Color {
+	if !(image.Point{X: x, Y: y}).In(img.Bounds()) {
+		return color.Transparent
+	}
+	return img.At(x, y)
+}
+
+// frameTiles returns the tile rectangles covering img in row-major order.
+func frameTiles(img image.Image) []image.Rectangle {
+	b := img.Bounds()
+	var tiles []image.Rectangle
+	for y := b.Min.Y; y < b.Max.Y; y += tileSize {
+		for x := b.Min.X; x < b.Max.X; x += tileSize {
+			tiles = append(tiles, image.Rect(x, y, x+tileSize, y+tileSize))
+		}
+	}
+	return tiles
+}
+
+// frameTiles8x16 returns the tile rectangles covering img in the order of 8x16 sprites, i.e.
+// the upper and the lower tile of each sprite after another.
+func frameTiles8x16(img image.Image) []image.Rectangle {
+	b := img.Bounds()
+	var tiles []image.Rectangle
+	for y := b.Min.Y; y < b.Max.Y; y += 2 * tileSize {
+		for x := b.Min.X; x < b.Max.X; x += tileSize {
+			tiles = append(tiles, image.Rect(x, y, x+tileSize, y+tileSize))
+			tiles = append(tiles, image.Rect(x, y+tileSize, x+tileSize, y+2*tileSize))
+		}
+	}
+	return tiles
+}
+
+// frameTilesGenesisSprite returns the tile rectangles covering img in the order of Genesis
+// sprites: img is split into sprites of up to 4x4 tiles in row-major order, and the tiles of
+// each sprite are ordered column-major.
+func frameTilesGenesisSprite(img image.Image) []image.Rectangle {
+	const spriteSize = 4 * tileSize
+	b := img.Bounds()
+	var tiles []image.Rectangle
+	for sy := b.Min.Y; sy < b.Max.Y; sy += spriteSize {
+		for sx := b.Min.X; sx < b.Max.X; sx += spriteSize {
+			for x := sx; x < sx+spriteSize && x < b.Max.X; x += tileSize {
+				for y := sy; y < sy+spriteSize && y < b.Max.Y; y += tileSize {
+					tiles = append(tiles, image.Rect(x, y, x+tileSize, y+tileSize))
+				}
+			}
+		}
+	}
+	return tiles
+}
+
+// tileCount returns how many tiles the format needs for a frame of the given size.
+func (f tileFormat) tileCount(size image.Point) int {
+	columns := (size.X + tileSize - 1) / tileSize
+	rows := (size.Y + f.TileHeight - 1) / f.TileHeight
+	return columns * rows * f.TileHeight / tileSize
+}
+
+// Encode returns the tile data and, if the target has one, the palette data of img.
+func (f tileFormat) Encode(img image.Image) ([]byte, []byte, error) {
+	tiles, palette, tilesErr := f.Tiles(img)
+	if tilesErr != nil {
+		return nil, nil, tilesErr
+	}
+	var data []byte
+	for _, t := range tiles {
+		data = append(data, f.Serialize(t)...)
+	}
+	return data, palette, nil
+}
+
+func luminance(c color.Color) uint32 {
+	r, g, b, _ := c.RGBA()
+	return (299*r + 587*g + 114*b) / 1000
+}
+
+// tilePalette returns the colors used in the tile with transparency first and the
+// remaining colors ordered from dark to light.
+func tilePalette(img image.Image, r image.Rectangle) []color.Color {
+	seen := make(map[color.RGBA64]bool)
+	var palette []color.Color
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.RGBA64Model.Convert(pixelAt(img, x, y)).(color.RGBA64)
+			if c.A == 0 {
+				c = color.RGBA64{}
+			}
+			if !seen[c] {
+				seen[c] = true
+				palette = append(palette, c)
+			}
+		}
+	}
+	sort.SliceStable(palette, func(i, j int) bool {
+		_, _, _, ai := palette[i].RGBA()
+		_, _, _, aj := palette[j].RGBA()
+		if (ai == 0) != (aj == 0) {
+			return ai == 0
+		}
+		return luminance(palette[i]) < luminance(palette[j])
+	})
+	return palette
+}
+
+// nesTiles indexes img for the NES. Every tile may use at most 4 colors, transparency included.
+func nesTiles(img image.Image) ([]tile, []byte, error) {
+	var tiles []tile
+	for i, r := range frameTiles(img) {
+		palette := tilePalette(img, r)
+		if len(palette) > 4 {
+			return nil, nil, fmt.Errorf("tile %d at %d,%d uses %d colors, but only 4 are possible", i, r.Min.X, r.Min.Y, len(palette))
+		}
+		index := color.Palette(palette)
+		var t tile
+		for y := 0; y < tileSize; y++ {
+			for x := 0; x < tileSize; x++ {
+				c := pixelAt(img, r.Min.X+x, r.Min.Y+y)
+				if _, _, _, a := c.RGBA(); a != 0 {
+					t[y][x] = uint8(index.Index(c))
+				}
+			}
+		}
+		tiles = append(tiles, t)
+	}
+	return tiles, nil, nil
+}
+
+// serializePlanar2bpp encodes a tile as NES CHR data: 16 bytes with the low bit plane first.
+func serializePlanar2bpp(t tile) []byte {
+	data := make([]byte, 2*tileSize)
+	for y := 0; y < tileSize; y++ {
+		for x := 0; x < tileSize; x++ {
+			bit := byte(0x80 >> uint(x))
+			if t[y][x]&1 != 0 {
+				data[y] |= bit
+			}
+			if t[y][x]&2 != 0 {
+				data[tileSize+y] |= bit
+			}
+		}
+	}
+	return data
+}
+
+// gbShade maps a color to one of the 4 Game Boy shades, 0 being the lightest and 3 the darkest.
+// Transparent pixels get shade 0.
+func gbShade(c color.Color) uint8 {
+	if _, _, _, a := c.RGBA(); a == 0 {
+		return 0
+	}
+	return uint8((0xffff - luminance(c)) * 4 / 0x10000)
+}
+
+// gbTiles returns a function indexing a frame for the Game Boy by mapping every pixel to its
+// shade, with the tiles in the order returned by tileOrder.
+func gbTiles(tileOrder func(img image.Image) []image.Rectangle) func(img image.Image) ([]tile, []byte, error) {
+	return func(img image.Image) ([]tile, []byte, error) {
+		var tiles []tile
+		for _, r := range tileOrder(img) {
+			var t tile
+			for y := 0; y < tileSize; y++ {
+				for x := 0; x < tileSize; x++ {
+					t[y][x] = gbShade(pixelAt(img, r.Min.X+x, r.Min.Y+y))
+				}
+			}
+			tiles = append(tiles, t)
+		}
+		return tiles, nil, nil
+	}
+}
+
+// serializeInterleaved2bpp encodes a tile as Game Boy 2bpp data with the bit planes interleaved
+// per row.
+func serializeInterleaved2bpp(t tile) []byte {
+	data := make([]byte, 0, 2*tileSize)
+	for y := 0; y < tileSize; y++ {
+		var low, high byte
+		for x := 0; x < tileSize; x++ {
+			bit := byte(0x80 >> uint(x))
+			if t[y][x]&1 != 0 {
+				low |= bit
+			}
+			if t[y][x]&2 != 0 {
+				high |= bit
+			}
+		}
+		data = append(data, low, high)
+	}
+	return data
+}
+
+// bgr555 converts a color to the 15 bit BGR format used by the GBA and SNES.
+func bgr555(c color.Color) uint16 {
+	r, g, b, _ := c.RGBA()
+	return uint16(r>>11) | uint16(g>>11)<<5 | uint16(b>>11)<<10
+}
+
+// genesisCRAM converts a color to the 9 bit BGR format of the Genesis color RAM.
+func genesisCRAM(c color.Color) uint16 {
+	r, g, b, _ := c.RGBA()
+	return uint16(r>>13)<<1 | uint16(g>>13)<<5 | uint16(b>>13)<<9
+}
+
+// indexedFrame assigns palette indices to the pixels of a frame. Index 0 is transparency, the
+// other colors are numbered in the order they first appear after being converted to the
+// target's color format.
+type indexedFrame struct {
+	img     image.Image
+	convert func(color.Color) uint16
+	palette []uint16
+	indices map[uint16]int
+}
+
+func newIndexedFrame(img image.Image, maxColors int, convert func(color.Color) uint16) (*indexedFrame, error) {
+	f := &indexedFrame{img: img, convert: convert, palette: []uint16{0}, indices: make(map[uint16]int)}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.At(x, y)
+			if _, _, _, a := c.RGBA(); a == 0 {
+				continue
+			}
+			converted := convert(c)
+			if _, ok := f.indices[converted]; !ok {
+				f.indices[converted] = len(f.palette)
+				f.palette = append(f.palette, converted)
+			}
+		}
+	}
+	if len(f.palette) > maxColors {
+		return nil, fmt.Errorf("frame uses %d colors including transparency, but only %d are possible", len(f.palette), maxColors)
+	}
+	return f, nil
+}
+
+func (f *indexedFrame) index(x, y int) uint8 {
+	c := pixelAt(f.img, x, y)
+	if _, _, _, a := c.RGBA(); a == 0 {
+		return 0
+	}
+	return uint8(f.indices[f.convert(c)])
+}
+
+// paletteData returns the palette as 16 bit values in the given byte order, padded to size entries.
+func (f *indexedFrame) paletteData(size int, order binary.ByteOrder) []byte {
+	data := make([]byte, 2*size)
+	for i, entry := range f.palette {
+		order.PutUint16(data[2*i:], entry)
+	}
+	return data
+}
+
+// paletteTiles returns a function indexing a frame with a 16 color palette in the target's
+// color format, with the tiles in the order returned by tileOrder.
+func paletteTiles(tileOrder func(img image.Image) []image.Rectangle, convert func(color.Color) uint16, byteOrder binary.ByteOrder) func(img image.Image) ([]tile, []byte, error) {
+	return func(img image.Image) ([]tile, []byte, error) {
+		f, indexErr := newIndexedFrame(img, 16, convert)
+		if indexErr != nil {
+			return nil, nil, indexErr
+		}
+		var tiles []tile
+		for _, r := range tileOrder(img) {
+			var t tile
+			for y := 0; y < tileSize; y++ {
+				for x := 0; x < tileSize; x++ {
+					t[y][x] = f.index(r.Min.X+x, r.Min.Y+y)
+				}
+			}
+			tiles = append(tiles, t)
+		}
+		return tiles, f.paletteData(16, byteOrder), nil
+	}
+}
+
+// serializeLinear4bpp returns a function encoding a tile with two pixels per byte, the left one
+// in the high nibble if leftHigh is set (Genesis) or in the low nibble otherwise (GBA).
+func serializeLinear4bpp(leftHigh bool) func(t tile) []byte {
+	return func(t tile) []byte {
+		data := make([]byte, 0, tileSize*tileSize/2)
+		for y := 0; y < tileSize; y++ {
+			for x := 0; x < tileSize; x += 2 {
+				if leftHigh {
+					data = append(data, t[y][x]<<4|t[y][x+1])
+				} else {
+					data = append(data, t[y][x]|t[y][x+1]<<4)
+				}
+			}
+		}
+		return data
+	}
+}
+
+// serializePlanar4bpp encodes a tile as SNES 4bpp planar data, with bit planes 0 and 1
+// interleaved per row followed by bit planes 2 and 3.
+func serializePlanar4bpp(t tile) []byte {
+	var planes [4][tileSize]byte
+	for y := 0; y < tileSize; y++ {
+		for x := 0; x < tileSize; x++ {
+			for plane := 0; plane < 4; plane++ {
+				if t[y][x]&(1<<uint(plane)) != 0 {
+					planes[plane][y] |= 0x80 >> uint(x)
+				}
+			}
+		}
+	}
+	data := make([]byte, 0, 4*tileSize)
+	for _, pair := range [][2]int{{0, 1}, {2, 3}} {
+		for y := 0; y < tileSize; y++ {
+			data = append(data, planes[pair[0]][y], planes[pair[1]][y])
+		}
+	}
+	return data
+}
+
+// mapEntry16 returns a function encoding 16 bit tile map entries with the tile index in the low
+// bits and the flip flags at the given bit positions.
+func mapEntry16(order binary.ByteOrder, flipHBit, flipVBit uint) func(index int, flipH, flipV bool) []byte {
+	return func(index int, flipH, flipV bool) []byte {
+		entry := uint16(index)
+		if flipH {
+			entry |= 1 << flipHBit
+		}
+		if flipV {
+			entry |= 1 << flipVBit
+		}
+		data := make([]byte, 2)
+		order.PutUint16(data, entry)
+		return data
+	}
+}
+
+// asmSymbol turns a file name into an assembler symbol.
+func asmSymbol(filename string) string {
+	base := strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, base)
+}
+
+// writeAsmInclude writes an RGBDS include file defining the index of the first tile and the tile
+// count of every frame, for the tile files being loaded consecutively in the order of files.
+func writeAsmInclude(filename string, format tileFormat, files []frameFile) error {
+	var inc bytes.Buffer
+	fmt.Fprintln(&inc, "; Generated by spritemap-explode. Tile indices assume the frame files below are")
+	fmt.Fprintln(&inc, "; loaded consecutively into VRAM in this order.")
+	index := 0
+	for _, f := range files {
+		symbol := asmSymbol(f.Filename)
+		count := format.tileCount(f.Size)
+		fmt.Fprintf(&inc, "DEF %s EQU $%02X ; %s\n", symbol, index, path.Base(tilesFilename(f.Filename, format)))
+		fmt.Fprintf(&inc, "DEF %s_COUNT EQU %d\n", symbol, count)
+		index += count
+	}
+	return writeFile(filename, inc.Bytes(), 0644)
+}
+
+func tilesFilename(frameFilename string, format tileFormat) string {
+	return strings.TrimSuffix(frameFilename, path.Ext(frameFilename)) + format.Extension
+}
+
+func savePalette(palette []byte, frameFilename string) error {
+	return writeFile(strings.TrimSuffix(frameFilename, path.Ext(frameFilename))+".pal", palette, 0644)
+}
+
+// saveTiles writes img encoded in the given tile format next to the frame file, and its palette
+// into a .pal file if the format has one.
+func saveTiles(img image.Image, format tileFormat, frameFilename string) error {
+	data, palette, encodeErr := format.Encode(img)
+	if encodeErr != nil {
+		return fmt.Errorf("cannot encode tiles for %s: %v", frameFilename, encodeErr)
+	}
+	if writeErr := writeFile(tilesFilename(frameFilename, format), data, 0644); writeErr != nil {
+		return writeErr
+	}
+	if palette != nil {
+		return savePalette(palette, frameFilename)
+	}
+	return nil
+}
+
+// tileSet collects the unique tiles of all frames. Every frame gets a tile map referencing the
+// tile set instead of its own tile data. Tiles that only differ by flipping are stored once if
+// the target's tile map supports flipping.
+type tileSet struct {
+	format  tileFormat
+	tiles   []tile
+	indices map[tile]int
+}
+
+func newTileSet(format tileFormat) *tileSet {
+	return &tileSet{format: format, indices: make(map[tile]int)}
+}
+
+// lookup returns the index of t, or of a flipped variant of t, in the tile set.
+func (s *tileSet) lookup(t tile) (index int, flipH, flipV bool, ok bool) {
+	if index, ok = s.indices[t]; ok || s.format.MapEntry == nil {
+		return index, false, false, ok
+	}
+	if index, ok = s.indices[t.flipH()]; ok {
+		return index, true, false, true
+	}
+	if index, ok = s.indices[t.flipV()]; ok {
+		return index, false, true, true
+	}
+	index, ok = s.indices[t.flipH().flipV()]
+	return index, ok, ok, ok
+}
+
+// add adds the tiles of img to the tile set and writes the tile map and palette next to the
+// frame file.
+func (s *tileSet) add(img image.Image, frameFilename string) error {
+	tiles, palette, tilesErr := s.format.Tiles(img)
+	if tilesErr != nil {
+		return fmt.Errorf("cannot encode tiles for %s: %v", frameFilename, tilesErr)
+	}
+	var tileMap []byte
+	for _, t := range tiles {
+		index, flipH, flipV, ok := s.lookup(t)
+		if !ok {
+			index = len(s.tiles)
+			s.indices[t] = index
+			s.tiles = append(s.tiles, t)
+		}
+		if s.format.MapEntry != nil {
+			tileMap = append(tileMap, s.format.MapEntry(index, flipH, flipV)...)
+		} else {
+			tileMap = append(tileMap, byte(index))
+		}
+	}
+	mapFilename := strings.TrimSuffix(frameFilename, path.Ext(frameFilename)) + ".map"
+	if writeErr := writeFile(mapFilename, tileMap, 0644); writeErr != nil {
+		return writeErr
+	}
+	if palette != nil {
+		return savePalette(palette, frameFilename)
+	}
+	return nil
+}
+
+// save writes the tile data of the tile set.
+func (s *tileSet) save(filename string) error {
+	if bits := s.format.indexBits(); len(s.tiles) > 1<<bits {
+		return fmt.Errorf("%d unique tiles do not fit into the %d bit tile indices of the target", len(s.tiles), bits)
+	}
+	var data []byte
+	for _, t := range s.tiles {
+		data = append(data, s.format.Serialize(t)...)
+	}
+	return writeFile(filename, data, 0644)
+}
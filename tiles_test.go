@@ -0,0 +1,21 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTileSetIndexLimit(t *testing.T) {
+	for name, limit := range map[string]int{"gba": 1024, "snes": 1024, "genesis": 2048, "nes": 256} {
+		s := newTileSet(tileFormats[name])
+		s.tiles = make([]tile, limit)
+		filename := filepath.Join(t.TempDir(), "tiles")
+		if saveErr := s.save(filename); saveErr != nil {
+			t.Errorf("%s: %d tiles fail: %v", name, limit, saveErr)
+		}
+		s.tiles = append(s.tiles, tile{})
+		if saveErr := s.save(filename); saveErr == nil {
+			t.Errorf("%s: %d tiles do not fail", name, limit+1)
+		}
+	}
+}
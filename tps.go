@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// tpsNode is an element of a TexturePacker project file. Structs and maps hold their values as
+// children alternating between a key element and the value element.
+type tpsNode struct {
+	XMLName  xml.Name
+	Text     string    `xml:",chardata"`
+	Children []tpsNode `xml:",any"`
+}
+
+// value returns the value of the key in the struct or map node, or nil if it has none.
+func (n *tpsNode) value(key string) *tpsNode {
+	for i := 0; i+1 < len(n.Children); i += 2 {
+		if n.Children[i].XMLName.Local == "key" && strings.TrimSpace(n.Children[i].Text) == key {
+			return &n.Children[i+1]
+		}
+	}
+	return nil
+}
+
+// text returns the trimmed text of the value of the key, or "" if there is none.
+func (n *tpsNode) text(key string) string {
+	if v := n.value(key); v != nil {
+		return strings.TrimSpace(v.Text)
+	}
+	return ""
+}
+
+// tpsProject holds the settings of a TexturePacker project file that apply to exploding and
+// packing. The file names are relative to the working directory.
+type tpsProject struct {
+	TextureFileName string
+	DataFileName    string
+	Trim            bool
+	ShapePadding    int
+	Scales          []float64
+}
+
+// loadTPS reads a TexturePacker .tps project file.
+func loadTPS(filename string) (tpsProject, error) {
+	var p tpsProject
+	data, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		return p, readErr
+	}
+	var root tpsNode
+	if unmarshalErr := xml.Unmarshal(data, &root); unmarshalErr != nil {
+		return p, unmarshalErr
+	}
+	if root.XMLName.Local != "data" || len(root.Children) != 1 || root.Children[0].XMLName.Local != "struct" {
+		return p, fmt.Errorf("not a TexturePacker project")
+	}
+	settings := &root.Children[0]
+
+	// variants are written to file names with their extension in place of {v}, of which the
+	// first variant is the one that is exploded
+	variant := ""
+	if variants := settings.value("autoSDSettings"); variants != nil {
+		for i, v := range variants.Children {
+			scale, parseErr := strconv.ParseFloat(v.text("scale"), 64)
+			if parseErr != nil || scale <= 0 {
+				return p, fmt.Errorf("invalid scale %q of variant %d", v.text("scale"), i)
+			}
+			p.Scales = append(p.Scales, scale)
+			if i == 0 {
+				variant = v.text("extension")
+			}
+		}
+	}
+	resolve := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return filepath.Join(filepath.Dir(filename), strings.ReplaceAll(name, "{v}", variant))
+	}
+	p.TextureFileName = resolve(settings.text("textureFileName"))
+	if dataFiles := settings.value("dataFileNames"); dataFiles != nil {
+		if dataFile := dataFiles.value("data"); dataFile != nil {
+			p.DataFileName = resolve(dataFile.text("name"))
+		}
+	}
+	if spriteSettings := settings.value("globalSpriteSettings"); spriteSettings != nil {
+		trimMode := spriteSettings.text("trimMode")
+		p.Trim = trimMode != "" && trimMode != "None"
+	}
+	if padding := settings.text("shapePadding"); padding != "" {
+		var atoiErr error
+		if p.ShapePadding, atoiErr = strconv.Atoi(padding); atoiErr != nil || p.ShapePadding < 0 {
+			return p, fmt.Errorf("invalid shape padding %q", padding)
+		}
+	}
+	return p, nil
+}
+
+// applyTPS uses the settings of the -tps project for the options that have not been given on the
+// command line: the trim mode for -trim and the scales of the variants other than the first for
+// -resolutions, its data file as -atlas when exploding its texture, or, if the file to process
+// is a directory, its texture as the -pack target with the shape padding as -pack-padding. A JSON
+// data file of the packed texture is written like -export texturepacker.
+func (a *args) applyTPS(p tpsProject, given map[string]bool) {
+	if info, statErr := os.Stat(a.Filename); statErr == nil && info.IsDir() {
+		if !given["pack"] {
+			a.Pack = p.TextureFileName
+		}
+		if !given["pack-padding"] {
+			a.PackPadding = uint(p.ShapePadding)
+		}
+		if strings.ToLower(filepath.Ext(p.DataFileName)) == ".json" && a.Pack == p.TextureFileName {
+			a.PackData = p.DataFileName
+			if !slices.Contains(a.Export, "texturepacker") {
+				a.Export = append(a.Export, "texturepacker")
+			}
+		}
+		return
+	}
+	if !given["trim"] {
+		a.Trim = p.Trim
+	}
+	if !given["resolutions"] && len(p.Scales) > 1 {
+		for _, scale := range p.Scales[1:] {
+			name := strconv.FormatFloat(scale/p.Scales[0], 'f', -1, 64)
+			a.Resolutions = append(a.Resolutions, resolution{Name: name + "x", Factor: scale / p.Scales[0]})
+		}
+	}
+	ext := strings.ToLower(filepath.Ext(p.DataFileName))
+	if !given["atlas"] && (ext == ".json" || ext == ".atlas") {
+		a.Atlas = p.DataFileName
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testTPS = `<?xml version="1.0" encoding="UTF-8"?>
+<data version="1.0">
+    <struct type="Settings">
+        <key>fileFormatVersion</key>
+        <int>6</int>
+        <key>autoSDSettings</key>
+        <array>
+            <struct type="AutoSDSettings">
+                <key>scale</key>
+                <double>1</double>
+                <key>extension</key>
+                <string>@2x</string>
+            </struct>
+            <struct type="AutoSDSettings">
+                <key>scale</key>
+                <double>0.5</double>
+                <key>extension</key>
+                <string></string>
+            </struct>
+        </array>
+        <key>shapePadding</key>
+        <uint>2</uint>
+        <key>textureFileName</key>
+        <filename>out/sheet{v}.png</filename>
+        <key>dataFileNames</key>
+        <map type="GFileNameMap">
+            <key>data</key>
+            <struct type="DataFile">
+                <key>name</key>
+                <filename>out/sheet{v}.json</filename>
+            </struct>
+        </map>
+        <key>globalSpriteSettings</key>
+        <struct type="SpriteSettings">
+            <key>trimMode</key>
+            <enum type="SpriteSettings::TrimMode">Trim</enum>
+        </struct>
+    </struct>
+</data>
+`
+
+func TestLoadTPS(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "sheet.tps")
+	if writeErr := os.WriteFile(filename, []byte(testTPS), 0644); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	project, loadErr := loadTPS(filename)
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+	expected := tpsProject{
+		TextureFileName: filepath.Join(dir, "out", "sheet@2x.png"),
+		DataFileName:    filepath.Join(dir, "out", "sheet@2x.json"),
+		Trim:            true,
+		ShapePadding:    2,
+		Scales:          []float64{1, 0.5},
+	}
+	if !reflect.DeepEqual(project, expected) {
+		t.Fatalf("got %+v, expected %+v", project, expected)
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		given    map[string]bool
+		expected args
+	}{
+		{
+			name:     "explode",
+			filename: expected.TextureFileName,
+			expected: args{Filename: expected.TextureFileName, Trim: true, Atlas: expected.DataFileName,
+				Resolutions: resolutionList{{Name: "0.5x", Factor: 0.5}}},
+		},
+		{
+			name:     "explode with arguments given",
+			filename: expected.TextureFileName,
+			given:    map[string]bool{"trim": true, "resolutions": true},
+			expected: args{Filename: expected.TextureFileName, Atlas: expected.DataFileName},
+		},
+		{
+			name:     "pack",
+			filename: dir,
+			expected: args{Filename: dir, Pack: expected.TextureFileName, PackPadding: 2, PackData: expected.DataFileName,
+				Export: exportList{"texturepacker"}},
+		},
+		{
+			name:     "pack into another file",
+			filename: dir,
+			given:    map[string]bool{"pack": true},
+			expected: args{Filename: dir, Pack: "other.png", PackPadding: 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := args{Filename: test.filename}
+			if test.given["pack"] {
+				a.Pack = "other.png"
+			}
+			a.applyTPS(project, test.given)
+			if !reflect.DeepEqual(a, test.expected) {
+				t.Errorf("got %+v, expected %+v", a, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoadTPSErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not XML", data: "sheet.png"},
+		{name: "not a project", data: `<plist><dict/></plist>`},
+		{name: "invalid scale", data: `<data><struct type="Settings"><key>autoSDSettings</key><array>` +
+			`<struct type="AutoSDSettings"><key>scale</key><double>0</double></struct></array></struct></data>`},
+		{name: "invalid padding", data: `<data><struct type="Settings"><key>shapePadding</key><uint>-1</uint></struct></data>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "sheet.tps")
+			if writeErr := os.WriteFile(filename, []byte(test.data), 0644); writeErr != nil {
+				t.Fatal(writeErr)
+			}
+			if project, loadErr := loadTPS(filename); loadErr == nil {
+				t.Errorf("got %+v, expected an error", project)
+			}
+		})
+	}
+}
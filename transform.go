@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hschendel/spritemap-explode/spritemapexplode"
+)
+
+// imageRotate90 rotates the image clockwise by 90 degrees.
+func imageRotate90(img image.Image) image.Image {
+	return spritemapexplode.Rotate(img, 90)
+}
+
+// imageRotate180 rotates the image by 180 degrees.
+func imageRotate180(img image.Image) image.Image {
+	return spritemapexplode.Rotate(img, 180)
+}
+
+// imageRotate270 rotates the image counterclockwise by 90 degrees.
+func imageRotate270(img image.Image) image.Image {
+	return spritemapexplode.Rotate(img, 270)
+}
+
+// transform applies the named transform of imageTransforms to the image. Mirrored frames of a
+// -normal-map get their normals corrected like the mirror variants.
+func (a *args) transform(name string, img image.Image) image.Image {
+	img = imageTransforms[name](img)
+	if !a.NormalMap {
+		return img
+	}
+	switch name {
+	case "mirror-y", "fliph":
+		return spritemapexplode.FlipNormals(img, true, false)
+	case "mirror-x", "flipv":
+		return spritemapexplode.FlipNormals(img, false, true)
+	case "rotate180":
+		return spritemapexplode.FlipNormals(img, true, true)
+	}
+	return img
+}
+
+// rotationList is the comma separated list of -rotate angles.
+type rotationList []int
+
+func (l *rotationList) String() string {
+	var angles []string
+	for _, degrees := range *l {
+		angles = append(angles, strconv.Itoa(degrees))
+	}
+	return strings.Join(angles, ",")
+}
+
+func (l *rotationList) Set(value string) error {
+	for _, field := range strings.Split(value, ",") {
+		degrees, atoiErr := strconv.Atoi(strings.TrimSpace(field))
+		if atoiErr != nil || degrees != 90 && degrees != 180 && degrees != 270 {
+			return fmt.Errorf("expected 90, 180 or 270 degrees")
+		}
+		*l = append(*l, degrees)
+	}
+	return nil
+}
+
+// transformSpec maps cells, whole rows and whole columns of the sprite map to transforms.
+type transformSpec struct {
+	cells   map[image.Point]string
+	rows    map[int]string
+	columns map[int]string
+}
+
+// loadTransformSpec reads a transform spec file. Every line maps a cell given as row:column, or
+// a whole row or column given as "row N" or "column N", to a transform, e.g. "2:3 = rotate90".
+// Empty lines and everything after a # are ignored.
+func loadTransformSpec(filename string) (*transformSpec, error) {
+	file, openErr := os.Open(filename)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+
+	spec := &transformSpec{cells: make(map[image.Point]string), rows: make(map[int]string), columns: make(map[int]string)}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		target, transform, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected <cell> = <transform>", lineNumber)
+		}
+		target, transform = strings.TrimSpace(target), strings.TrimSpace(transform)
+		if _, known := imageTransforms[transform]; !known || transform == "" {
+			return nil, fmt.Errorf("line %d: unknown transform %q", lineNumber, transform)
+		}
+
+		fields := strings.Fields(target)
+		var parseErr error
+		switch {
+		case len(fields) == 2 && fields[0] == "row":
+			var row int
+			row, parseErr = strconv.Atoi(fields[1])
+			spec.rows[row] = transform
+		case len(fields) == 2 && fields[0] == "column":
+			var column int
+			column, parseErr = strconv.Atoi(fields[1])
+			spec.columns[column] = transform
+		case len(fields) == 1 && strings.Contains(target, ":"):
+			var index cellIndex
+			parseErr = index.Set(strings.Replace(target, ":", ",", 1))
+			spec.cells[image.Pt(index.Column, index.Row)] = transform
+		default:
+			parseErr = fmt.Errorf("expected row:column, row N or column N")
+		}
+		if parseErr != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, parseErr)
+		}
+	}
+	return spec, scanner.Err()
+}
+
+// Transform returns the transform for the cell, preferring a transform given for the cell itself
+// over one for its row, and that over one for its column.
+func (s *transformSpec) Transform(row int, column int) string {
+	if transform, ok := s.cells[image.Pt(column, row)]; ok {
+		return transform
+	}
+	if transform, ok := s.rows[row]; ok {
+		return transform
+	}
+	return s.columns[column]
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/hschendel/spritemap-explode/spritemapexplode"
+	"golang.org/x/term"
+)
+
+// gridTUI lets the user adjust the frame size, margin, spacing and offset interactively while
+// showing a downscaled view of the sprite map with the grid drawn on top, using Unicode half
+// blocks and 24 bit colors.
+type gridTUI struct {
+	img     image.Image
+	width   int
+	height  int
+	margin  int
+	spacing int
+	offset  image.Point
+	cells   []spritemapexplode.Cell
+	out     *bufio.Writer
+}
+
+// layout computes the cells of the current grid, which are none if no frame fits.
+func (t *gridTUI) layout() {
+	t.cells, _ = spritemapexplode.Grid(t.img.Bounds(), spritemapexplode.Options{
+		FrameWidth:  t.width,
+		FrameHeight: t.height,
+		Margin:      t.margin,
+		Spacing:     t.spacing,
+		Offset:      t.offset,
+	})
+}
+
+// tuiPixel returns the terminal color of the sprite map pixel at x, y: the top and left edges of
+// the frames in magenta, pixels outside of all frames in dark magenta, transparency as a dark
+// checkerboard, and everything else blended onto it.
+func (t *gridTUI) tuiPixel(x, y, scale int) (r, g, b uint32) {
+	checker := uint32(0x30)
+	if (x/(4*scale)+y/(4*scale))%2 == 0 {
+		checker = 0x40
+	}
+	p := image.Point{X: x, Y: y}
+	if !p.In(t.img.Bounds()) {
+		return 0, 0, 0
+	}
+	inFrame := false
+	for _, c := range t.cells {
+		if p.In(c.Rect) {
+			if x-c.Rect.Min.X < scale || y-c.Rect.Min.Y < scale {
+				return 0xff, 0, 0xff
+			}
+			inFrame = true
+			break
+		}
+	}
+	if !inFrame {
+		return 0x50, 0, 0x50
+	}
+	cr, cg, cb, ca := t.img.At(x, y).RGBA()
+	blend := func(c uint32) uint32 { return (c + checker*257*(0xffff-ca)/0xffff) >> 8 }
+	return blend(cr), blend(cg), blend(cb)
+}
+
+func (t *gridTUI) draw(columns, rows int) {
+	b := t.img.Bounds()
+	rows -= 2
+	scale := max((b.Dx()+columns-1)/columns, (b.Dy()+2*rows-1)/(2*rows), 1)
+
+	fmt.Fprint(t.out, "\x1b[H\x1b[2J")
+	for ty := 0; ty < rows && ty*2*scale < b.Dy(); ty++ {
+		for tx := 0; tx < columns && tx*scale < b.Dx(); tx++ {
+			x := b.Min.X + tx*scale
+			y := b.Min.Y + ty*2*scale
+			fr, fg, fb := t.tuiPixel(x, y, scale)
+			br, bg, bb := t.tuiPixel(x, y+scale, scale)
+			fmt.Fprintf(t.out, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", fr, fg, fb, br, bg, bb)
+		}
+		fmt.Fprint(t.out, "\x1b[0m\r\n")
+	}
+	frameColumns, frameRows := 0, 0
+	for _, c := range t.cells {
+		frameColumns, frameRows = max(frameColumns, c.Column+1), max(frameRows, c.Row+1)
+	}
+	fmt.Fprintf(t.out, "%s (%dx%d frames, 1:%d)  ←→ width  ↓↑ height  m/M margin  s/S spacing  x/X y/Y offset"+
+		"  enter: explode  p: print flags  q: quit", t.flags(), frameColumns, frameRows, scale)
+	t.out.Flush()
+}
+
+// flags returns the command line arguments for the current grid.
+func (t *gridTUI) flags() string {
+	return fmt.Sprintf("-width %d -height %d -margin %d -spacing %d -offset-x %d -offset-y %d",
+		t.width, t.height, t.margin, t.spacing, t.offset.X, t.offset.Y)
+}
+
+// runTUI shows the grid TUI for the sprite map of a. If the user chooses to explode, the frame
+// size, margin, spacing and offset in a are updated and true is returned.
+func runTUI(a *args) (bool, error) {
+	file, openErr := os.Open(a.Filename)
+	if openErr != nil {
+		return false, openErr
+	}
+	img, _, decodeErr := decodeImage(file, a.Filename)
+	file.Close()
+	if decodeErr != nil {
+		return false, decodeErr
+	}
+
+	t := &gridTUI{img: img, width: 32, height: 32, out: bufio.NewWriter(os.Stdout)}
+	if a.FrameWidth != 0 {
+		t.width = int(a.FrameWidth)
+	} else if a.Columns != 0 {
+		t.width = img.Bounds().Dx() / int(a.Columns)
+	}
+	if a.FrameHeight != 0 {
+		t.height = int(a.FrameHeight)
+	} else if a.Rows != 0 {
+		t.height = img.Bounds().Dy() / int(a.Rows)
+	}
+	t.width = min(max(t.width, 1), img.Bounds().Dx())
+	t.height = min(max(t.height, 1), img.Bounds().Dy())
+	t.margin, t.spacing = int(a.Margin), int(a.Spacing)
+	t.offset = image.Pt(int(a.OffsetX), int(a.OffsetY))
+
+	fd := int(os.Stdin.Fd())
+	oldState, rawErr := term.MakeRaw(fd)
+	if rawErr != nil {
+		return false, rawErr
+	}
+	fmt.Fprint(t.out, "\x1b[?25l")
+	defer func() {
+		fmt.Fprint(t.out, "\x1b[0m\x1b[?25h\r\n")
+		t.out.Flush()
+		term.Restore(fd, oldState)
+	}()
+
+	in := bufio.NewReader(os.Stdin)
+	for {
+		columns, rows, sizeErr := term.GetSize(int(os.Stdout.Fd()))
+		if sizeErr != nil || columns < 1 || rows < 3 {
+			columns, rows = 80, 24
+		}
+		t.layout()
+		t.draw(columns, rows)
+
+		key, readErr := in.ReadByte()
+		if readErr != nil {
+			return false, readErr
+		}
+		switch key {
+		case 'q', 3:
+			return false, nil
+		case 'p':
+			fmt.Fprintf(t.out, "\r\n%s", t.flags())
+			return false, nil
+		case '\r', '\n':
+			a.FrameWidth, a.FrameHeight = uint(t.width), uint(t.height)
+			a.Margin, a.Spacing = uint(t.margin), uint(t.spacing)
+			a.OffsetX, a.OffsetY = uint(t.offset.X), uint(t.offset.Y)
+			a.Columns, a.Rows = 0, 0
+			return true, nil
+		case 'm':
+			t.margin = max(t.margin-1, 0)
+		case 'M':
+			t.margin = min(t.margin+1, img.Bounds().Dx()/2, img.Bounds().Dy()/2)
+		case 's':
+			t.spacing = max(t.spacing-1, 0)
+		case 'S':
+			t.spacing = min(t.spacing+1, img.Bounds().Dx(), img.Bounds().Dy())
+		case 'x':
+			t.offset.X = max(t.offset.X-1, 0)
+		case 'X':
+			t.offset.X = min(t.offset.X+1, img.Bounds().Dx()-1)
+		case 'y':
+			t.offset.Y = max(t.offset.Y-1, 0)
+		case 'Y':
+			t.offset.Y = min(t.offset.Y+1, img.Bounds().Dy()-1)
+		case 0x1b:
+			if next, _ := in.ReadByte(); next != '[' {
+				return false, nil
+			}
+			arrow, _ := in.ReadByte()
+			switch arrow {
+			case 'C':
+				t.width = min(t.width+1, img.Bounds().Dx())
+			case 'D':
+				t.width = max(t.width-1, 1)
+			case 'A':
+				t.height = min(t.height+1, img.Bounds().Dy())
+			case 'B':
+				t.height = max(t.height-1, 1)
+			}
+		}
+	}
+}
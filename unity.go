@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// unityGUIDPattern finds the asset GUID of an existing Unity .meta file.
+var unityGUIDPattern = regexp.MustCompile(`(?m)^guid: ([0-9a-f]{32})$`)
+
+// unityID returns a stable 32 digit hex ID derived from the parts.
+func unityID(parts ...string) string {
+	sum := md5.Sum([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeUnity writes <image>.meta next to the sprite map, importing it as a sprite sheet in
+// Multiple mode with one sprite per region. The file is not written into -out, as Unity only reads
+// the .meta file next to the asset. Unity counts y from the bottom. The GUID of an existing .meta
+// file is kept, so references in the Unity project stay intact. Names are double quoted, as
+// frame names may contain characters with a meaning in YAML.
+func writeUnity(e *sheetExport) error {
+	filename := e.Image + ".meta"
+	guid := unityID(e.Image)
+	if existing, readErr := os.ReadFile(filename); readErr == nil {
+		if match := unityGUIDPattern.FindSubmatch(existing); match != nil {
+			guid = string(match[1])
+		}
+	}
+
+	var meta strings.Builder
+	fmt.Fprintf(&meta, "fileFormatVersion: 2\nguid: %s\nTextureImporter:\n", guid)
+	meta.WriteString("  serializedVersion: 12\n  textureType: 8\n  spriteMode: 2\n  spritePixelsToUnits: 100\n" +
+		"  alphaIsTransparency: 1\n  filterMode: 0\n  textureCompression: 0\n  spriteSheet:\n    serializedVersion: 2\n    sprites:\n")
+	for _, r := range e.Regions {
+		fmt.Fprintf(&meta, "    - serializedVersion: 2\n      name: %s\n", strconv.Quote(r.Name))
+		fmt.Fprintf(&meta, "      rect:\n        serializedVersion: 2\n        x: %d\n        y: %d\n        width: %d\n        height: %d\n",
+			r.Rect.Min.X, e.Size.Y-r.Rect.Max.Y, r.Rect.Dx(), r.Rect.Dy())
+		fmt.Fprintf(&meta, "      alignment: 0\n      pivot: {x: 0.5, y: 0.5}\n      border: {x: 0, y: 0, z: 0, w: 0}\n      spriteID: %s\n",
+			unityID(e.Image, r.Name))
+	}
+	return writeFile(filename, []byte(meta.String()), 0644)
+}
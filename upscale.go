@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strings"
+)
+
+// scaleFilter is a pixel art upscaler of -scale-filter enlarging an image by Factor. Larger
+// factors are reached by applying it repeatedly.
+type scaleFilter struct {
+	Factor int
+	Scale  func(src *image.NRGBA) *image.NRGBA
+}
+
+// scaleFilters are the supported values of -scale-filter besides nearest.
+var scaleFilters = map[string]scaleFilter{
+	"scale2x": {Factor: 2, Scale: scale2x},
+	"scale3x": {Factor: 3, Scale: scale3x},
+}
+
+// scaleFilterNames returns the names of the supported -scale-filter values.
+func scaleFilterNames() string {
+	names := []string{"nearest"}
+	for name := range scaleFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return strings.Join(names, ", ")
+}
+
+// scaleSteps returns how often the filter has to be applied to enlarge by factor, or an error if
+// factor is not a power of the filter's factor.
+func (f scaleFilter) scaleSteps(factor int) (int, error) {
+	steps := 0
+	for ; factor > 1 && factor%f.Factor == 0; factor /= f.Factor {
+		steps++
+	}
+	if factor != 1 {
+		return 0, fmt.Errorf("the factor must be a power of %d", f.Factor)
+	}
+	return steps, nil
+}
+
+// nrgbaAt returns the pixel of src at x, y, clamping the coordinates to the image so that the
+// border pixels repeat.
+func nrgbaAt(src *image.NRGBA, x int, y int) color.NRGBA {
+	b := src.Bounds()
+	return src.NRGBAAt(min(max(x, b.Min.X), b.Max.X-1), min(max(y, b.Min.Y), b.Max.Y-1))
+}
+
+// scale2x enlarges src by 2 with the Scale2x algorithm, which rounds off diagonal edges without
+// introducing new colors.
+func scale2x(src *image.NRGBA) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rectangle{Min: b.Min, Max: b.Min.Add(b.Size().Mul(2))})
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			e := src.NRGBAAt(x, y)
+			up, left, right, down := nrgbaAt(src, x, y-1), nrgbaAt(src, x-1, y), nrgbaAt(src, x+1, y), nrgbaAt(src, x, y+1)
+			e0, e1, e2, e3 := e, e, e, e
+			if up != down && left != right {
+				if left == up {
+					e0 = left
+				}
+				if up == right {
+					e1 = right
+				}
+				if left == down {
+					e2 = left
+				}
+				if down == right {
+					e3 = right
+				}
+			}
+			dx, dy := b.Min.X+2*(x-b.Min.X), b.Min.Y+2*(y-b.Min.Y)
+			dst.SetNRGBA(dx, dy, e0)
+			dst.SetNRGBA(dx+1, dy, e1)
+			dst.SetNRGBA(dx, dy+1, e2)
+			dst.SetNRGBA(dx+1, dy+1, e3)
+		}
+	}
+	return dst
+}
+
+// scale3x enlarges src by 3 with the Scale3x algorithm, the variant of Scale2x for a factor of 3.
+func scale3x(src *image.NRGBA) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rectangle{Min: b.Min, Max: b.Min.Add(b.Size().Mul(3))})
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var n [9]color.NRGBA
+			for i := range n {
+				n[i] = nrgbaAt(src, x+i%3-1, y+i/3-1)
+			}
+			a, up, c, left, e, right, g, down, i := n[0], n[1], n[2], n[3], n[4], n[5], n[6], n[7], n[8]
+			out := [9]color.NRGBA{e, e, e, e, e, e, e, e, e}
+			if up != down && left != right {
+				if left == up {
+					out[0] = left
+				}
+				if left == up && e != c || up == right && e != a {
+					out[1] = up
+				}
+				if up == right {
+					out[2] = right
+				}
+				if left == up && e != g || left == down && e != a {
+					out[3] = left
+				}
+				if up == right && e != i || down == right && e != c {
+					out[5] = right
+				}
+				if left == down {
+					out[6] = left
+				}
+				if left == down && e != i || down == right && e != g {
+					out[7] = down
+				}
+				if down == right {
+					out[8] = right
+				}
+			}
+			dx, dy := b.Min.X+3*(x-b.Min.X), b.Min.Y+3*(y-b.Min.Y)
+			for j, p := range out {
+				dst.SetNRGBA(dx+j%3, dy+j/3, p)
+			}
+		}
+	}
+	return dst
+}
+
+// imageUpscale enlarges the image by factor with the named -scale-filter.
+func imageUpscale(img image.Image, factor int, filter string) image.Image {
+	f, ok := scaleFilters[filter]
+	if !ok {
+		return imageScale(img, factor)
+	}
+	steps, _ := f.scaleSteps(factor)
+	scaled := image.NewNRGBA(img.Bounds())
+	draw.Draw(scaled, scaled.Bounds(), img, img.Bounds().Min, draw.Src)
+	for range steps {
+		scaled = f.Scale(scaled)
+	}
+	return scaled
+}
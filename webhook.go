@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookSummary is the JSON document posted to the webhook once a sprite map has been processed.
+// Durations holds the display duration of every frame in milliseconds.
+type webhookSummary struct {
+	Source    string   `json:"source"`
+	Frames    []string `json:"frames"`
+	Durations []int    `json:"durations"`
+	Error     string   `json:"error,omitempty"`
+}
+
+func newWebhookSummary(source string, files []frameFile, explodeErr error) webhookSummary {
+	summary := webhookSummary{Source: source, Frames: make([]string, len(files)), Durations: make([]int, len(files))}
+	for i, f := range files {
+		summary.Frames[i] = f.Filename
+		summary.Durations[i] = f.Duration
+	}
+	if explodeErr != nil {
+		summary.Error = explodeErr.Error()
+	}
+	return summary
+}
+
+func postWebhook(url string, summary webhookSummary) error {
+	body, marshalErr := json.Marshal(summary)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, postErr := client.Post(url, "application/json", bytes.NewReader(body))
+	if postErr != nil {
+		return postErr
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}